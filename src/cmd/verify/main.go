@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"logger/remotes/blockchain"
+)
+
+// verify - check an exported chain offline, without database or the running service
+//
+// Usage:
+//
+//	verify -key pub.asc [-scheme pgp] [-in blocks.ndjson] [-json]
+//
+// The blocks can be a json array or newline delimited json, read from stdin when -in is "-".
+// Exit codes: 0 valid chain, 1 invalid chain, 2 bad usage or unreadable input.
+func main() {
+	in := flag.String("in", "-", "blocks file, json array or ndjson, - for stdin")
+	keyFile := flag.String("key", "", "public key file (armored pgp or pem)")
+	scheme := flag.String("scheme", blockchain.SchemePGP, "public key scheme: pgp, ed25519 or ecdsa-p256")
+	asJSON := flag.Bool("json", false, "print the report as json")
+	flag.Parse()
+
+	if *keyFile == "" {
+		fail("missing -key")
+	}
+
+	pubKey, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fail("reading public key: %v", err)
+	}
+
+	verifier, err := blockchain.NewVerifier(*scheme, string(pubKey))
+	if err != nil {
+		fail("loading public key: %v", err)
+	}
+
+	blocks, err := readBlocks(*in)
+	if err != nil {
+		fail("reading blocks: %v", err)
+	}
+
+	chain := blockchain.NewBlockChain("")
+	chain.AddVerifier(verifier)
+
+	r := verifyChain(chain, blocks)
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(r, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		r.print(os.Stdout)
+	}
+
+	if !r.Valid {
+		os.Exit(1)
+	}
+}
+
+func readBlocks(in string) ([]blockchain.Block, error) {
+	var r io.Reader = os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	blocks, err := blockchain.ReadBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].SeqID < blocks[j].SeqID })
+	return blocks, nil
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "verify: "+format+"\n", args...)
+	os.Exit(2)
+}
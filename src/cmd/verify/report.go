@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"logger/remotes/blockchain"
+)
+
+type failure struct {
+	SeqID   uint
+	BlockID string
+	Error   string
+}
+
+type report struct {
+	Valid      bool
+	Blocks     int
+	FirstSeqID uint
+	LastSeqID  uint
+
+	// FirstBrokenSeqID its the lowest seqID with any failure
+	FirstBrokenSeqID *uint `json:",omitempty"`
+
+	// ValidationError its the error returned by the chain validation
+	ValidationError string `json:",omitempty"`
+	RotationError   string `json:",omitempty"`
+
+	SignatureFailures []failure
+	MetadataTampered  []failure
+	HashMismatches    []failure
+	BrokenLinks       []failure
+}
+
+func newFailure(block *blockchain.Block, err string) failure {
+	return failure{SeqID: block.SeqID, BlockID: block.ID.String(), Error: err}
+}
+
+// verifyChain - run the chain validation and check every block to report all the failures
+// blocks must be ordered by seqID
+func verifyChain(chain *blockchain.BlockChain, blocks []blockchain.Block) report {
+	r := report{Blocks: len(blocks)}
+	if len(blocks) == 0 {
+		r.Valid = true
+		return r
+	}
+
+	r.FirstSeqID = blocks[0].SeqID
+	r.LastSeqID = blocks[len(blocks)-1].SeqID
+
+	// learn the keys announced on the dump before checking each block
+	rotations := []blockchain.Block{}
+	for _, block := range blocks {
+		if block.IsKeyRotation() {
+			rotations = append(rotations, block)
+		}
+	}
+	if err := chain.LearnRotations(rotations); err != nil {
+		r.RotationError = err.Error()
+	}
+
+	if err := chain.ValidateBlocks(blocks); err != nil {
+		r.ValidationError = err.Error()
+	}
+
+	for i := range blocks {
+		block := &blocks[i]
+
+		if err := chain.ValidateSignature(block); err != nil {
+			r.SignatureFailures = append(r.SignatureFailures, newFailure(block, err.Error()))
+		}
+
+		if err := block.CheckMetadata(); err != nil {
+			r.MetadataTampered = append(r.MetadataTampered, newFailure(block, err.Error()))
+		}
+
+		if hash := block.CalcHash(); hash != block.Hash {
+			r.HashMismatches = append(r.HashMismatches, newFailure(block, fmt.Sprintf("%s != %s", block.Hash, hash)))
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		last := &blocks[i-1]
+		if block.LastBlockID != last.ID || block.LastBlockHash != last.Hash || block.SeqID != last.SeqID+1 {
+			r.BrokenLinks = append(r.BrokenLinks, newFailure(block, fmt.Sprintf("previous block %d [%s] hash [%s]", last.SeqID, last.ID, last.Hash)))
+		}
+	}
+
+	for _, failures := range [][]failure{r.SignatureFailures, r.MetadataTampered, r.HashMismatches, r.BrokenLinks} {
+		if len(failures) > 0 && (r.FirstBrokenSeqID == nil || failures[0].SeqID < *r.FirstBrokenSeqID) {
+			seqID := failures[0].SeqID
+			r.FirstBrokenSeqID = &seqID
+		}
+	}
+
+	r.Valid = r.ValidationError == "" && r.RotationError == "" && r.FirstBrokenSeqID == nil
+	return r
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintf(w, "blocks: %d [%d - %d]\n", r.Blocks, r.FirstSeqID, r.LastSeqID)
+
+	if r.Valid {
+		fmt.Fprintln(w, "result: VALID")
+		return
+	}
+
+	fmt.Fprintln(w, "result: INVALID")
+	if r.FirstBrokenSeqID != nil {
+		fmt.Fprintf(w, "first broken seqID: %d\n", *r.FirstBrokenSeqID)
+	}
+	if r.ValidationError != "" {
+		fmt.Fprintf(w, "validation: %s\n", r.ValidationError)
+	}
+	if r.RotationError != "" {
+		fmt.Fprintf(w, "key rotations: %s\n", r.RotationError)
+	}
+
+	printFailures(w, "signature failures", r.SignatureFailures)
+	printFailures(w, "metadata tampered", r.MetadataTampered)
+	printFailures(w, "hash mismatches", r.HashMismatches)
+	printFailures(w, "broken links", r.BrokenLinks)
+}
+
+func printFailures(w io.Writer, title string, failures []failure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d\n", title, len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(w, "  seqID %d block %s: %s\n", f.SeqID, f.BlockID, f.Error)
+	}
+}
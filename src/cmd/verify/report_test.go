@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func _mockChain(t *testing.T, size int) (blockchain.Verifier, []blockchain.Block) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(blockchain.NewEd25519Signer(priv))
+	chain.AddVerifier(blockchain.NewEd25519Verifier(pub))
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < size; i++ {
+		_, err = chain.AppendBlock(blockchain.NewBlock("sauron", map[string]interface{}{"i": i}))
+		assert.Nil(t, err)
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+	return blockchain.NewEd25519Verifier(pub), blocks
+}
+
+func TestVerifyChain(t *testing.T) {
+	verifier, blocks := _mockChain(t, 4)
+
+	chain := blockchain.NewBlockChain("")
+	chain.AddVerifier(verifier)
+
+	r := verifyChain(chain, blocks)
+	assert.True(t, r.Valid)
+	assert.Equal(t, 5, r.Blocks)
+
+	blocks[2].TransactionStr = `{"system_id":"sauron","i":100}`
+	blocks[4].Signature = blocks[3].Signature
+
+	r = verifyChain(chain, blocks)
+	assert.False(t, r.Valid)
+	assert.NotEmpty(t, r.ValidationError)
+	assert.Equal(t, uint(2), *r.FirstBrokenSeqID)
+	assert.Len(t, r.HashMismatches, 1)
+	assert.Len(t, r.SignatureFailures, 1)
+	assert.Equal(t, uint(4), r.SignatureFailures[0].SeqID)
+}
+
+func TestVerifyChainMetadata(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(blockchain.NewEd25519Signer(priv))
+	chain.AddVerifier(blockchain.NewEd25519Verifier(pub))
+	chain.SetSignMetadata(true)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(blockchain.NewBlock("sauron", map[string]interface{}{"i": 1}))
+	assert.Nil(t, err)
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	blocks[1].SystemID = "gandalf"
+
+	r := verifyChain(chain, blocks)
+	assert.False(t, r.Valid)
+	assert.Len(t, r.MetadataTampered, 1)
+	assert.Equal(t, uint(1), *r.FirstBrokenSeqID)
+}
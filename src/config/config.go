@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
 	"strconv"
 	"time"
 
@@ -21,6 +23,10 @@ type Config struct {
 	SystemID        string
 	Propertyes      c.Configurations
 	BlockChain      blockChain
+	Batch           batch
+	Validation      validation
+	Alert           alert
+	Archive         archive
 	PostgreSQL      string
 	Server          server `json:"server"`
 	SnakeByDefault  bool
@@ -48,6 +54,51 @@ type blockChain struct {
 	PrivKey    string
 	PubKey     string
 	Passphrase string
+	// Scheme used to sign new blocks (pgp, ed25519, ecdsa-p256)
+	Scheme string
+	// HashAlgorithm digest used to hash new blocks (sha256, sha512, sha3-256, blake2b-256)
+	HashAlgorithm string
+	// SignMetadata signs the system, tags, creation date and signer of new blocks
+	SignMetadata bool
+	// SubChains gives each system its own chain, anchored on the global chain each AnchorInterval
+	SubChains      bool
+	AnchorInterval time.Duration
+	// VerifyKeys extra public keys by scheme, used to verify blocks signed with older schemes
+	VerifyKeys map[string]string
+	// Next key, when setted the chain rotates to it announcing on a rotation block
+	NextPrivKey    string
+	NextPassphrase string
+	NextScheme     string
+}
+
+type batch struct {
+	// Size max of entries sealed in a block, 1 keeps one entry per block
+	Size int
+	// MaxWait to seal an incomplete batch
+	MaxWait time.Duration
+	// MaxBulk logs accepted by a single bulk request
+	MaxBulk int
+}
+
+type validation struct {
+	// BatchSize of blocks read by the full validation, only one batch is kept on memory
+	BatchSize int
+	// Workers verifying the blocks signatures and hashes concurrently
+	Workers int
+	// Interval of the background validation of the new blocks, 0 disables it
+	Interval time.Duration
+	// CheckpointBlocks the head must advance for the background validation to sign a new checkpoint
+	CheckpointBlocks int
+}
+
+type archive struct {
+	// MaxImportBytes of an archive received by the import
+	MaxImportBytes int64
+}
+
+type alert struct {
+	// Webhook receiving the alerts as json
+	Webhook string
 }
 
 // Config global
@@ -69,8 +120,8 @@ func (c *Config) getEnvOrFile(key string) string {
 	return cfg.File[key]
 }
 
-// Load config
-func Load(args []string) {
+// Load config, returns the error of the settings that can not be parsed
+func Load(args []string) error {
 	godotenv.Load(".env")
 	cfile := "./config.json"
 	cfg = &Config{
@@ -102,6 +153,57 @@ func Load(args []string) {
 	cfg.BlockChain.PubKey = cfg.getEnvOrFile("BLOCKCHAIN_PUB_KEY")
 	cfg.BlockChain.PrivKey = cfg.getEnvOrFile("BLOCKCHAIN_PRIV_KEY")
 	cfg.BlockChain.Passphrase = cfg.getEnvOrFile("BLOCKCHAIN_PRIV_KEY_PASS")
+	cfg.BlockChain.Scheme = cfg.getEnvOrFile("BLOCKCHAIN_SIGN_SCHEME")
+	cfg.BlockChain.HashAlgorithm = cfg.getEnvOrFile("BLOCKCHAIN_HASH_ALGORITHM")
+	cfg.BlockChain.SignMetadata, _ = strconv.ParseBool(cfg.getEnvOrFile("BLOCKCHAIN_SIGN_METADATA"))
+	cfg.BlockChain.SubChains, _ = strconv.ParseBool(cfg.getEnvOrFile("BLOCKCHAIN_SUB_CHAINS"))
+	anchorInterval, _ := strconv.Atoi(cfg.getEnvOrFile("BLOCKCHAIN_ANCHOR_INTERVAL_MS"))
+	cfg.BlockChain.AnchorInterval = time.Duration(anchorInterval) * time.Millisecond
+	if verifyKeys := cfg.getEnvOrFile("BLOCKCHAIN_VERIFY_KEYS"); verifyKeys != "" {
+		err := json.Unmarshal([]byte(verifyKeys), &cfg.BlockChain.VerifyKeys)
+		if err != nil {
+			return fmt.Errorf("parsing BLOCKCHAIN_VERIFY_KEYS: %w", err)
+		}
+	}
+	cfg.BlockChain.NextPrivKey = cfg.getEnvOrFile("BLOCKCHAIN_NEXT_PRIV_KEY")
+	cfg.BlockChain.NextPassphrase = cfg.getEnvOrFile("BLOCKCHAIN_NEXT_PRIV_KEY_PASS")
+	cfg.BlockChain.NextScheme = cfg.getEnvOrFile("BLOCKCHAIN_NEXT_SIGN_SCHEME")
+
+	cfg.Batch.Size, _ = strconv.Atoi(cfg.getEnvOrFile("BATCH_SIZE"))
+	if cfg.Batch.Size < 1 {
+		cfg.Batch.Size = 1
+	}
+	batchMaxWait, _ := strconv.Atoi(cfg.getEnvOrFile("BATCH_MAX_WAIT_MS"))
+	if batchMaxWait < 1 {
+		batchMaxWait = 200
+	}
+	cfg.Batch.MaxWait = time.Duration(batchMaxWait) * time.Millisecond
+	cfg.Batch.MaxBulk, _ = strconv.Atoi(cfg.getEnvOrFile("BATCH_MAX_BULK"))
+	if cfg.Batch.MaxBulk < 1 {
+		cfg.Batch.MaxBulk = 1000
+	}
+
+	cfg.Validation.BatchSize, _ = strconv.Atoi(cfg.getEnvOrFile("VALIDATION_BATCH_SIZE"))
+	if cfg.Validation.BatchSize < 1 {
+		cfg.Validation.BatchSize = 1000
+	}
+	cfg.Validation.Workers, _ = strconv.Atoi(cfg.getEnvOrFile("VALIDATION_WORKERS"))
+	if cfg.Validation.Workers < 1 {
+		cfg.Validation.Workers = runtime.NumCPU()
+	}
+	validationInterval, _ := strconv.Atoi(cfg.getEnvOrFile("VALIDATION_INTERVAL_MS"))
+	cfg.Validation.Interval = time.Duration(validationInterval) * time.Millisecond
+	cfg.Validation.CheckpointBlocks, _ = strconv.Atoi(cfg.getEnvOrFile("VALIDATION_CHECKPOINT_BLOCKS"))
+	if cfg.Validation.CheckpointBlocks < 1 {
+		cfg.Validation.CheckpointBlocks = 1000
+	}
+
+	cfg.Alert.Webhook = cfg.getEnvOrFile("ALERT_WEBHOOK")
+
+	cfg.Archive.MaxImportBytes, _ = strconv.ParseInt(cfg.getEnvOrFile("IMPORT_MAX_BYTES"), 10, 64)
+	if cfg.Archive.MaxImportBytes < 1 {
+		cfg.Archive.MaxImportBytes = 256 << 20
+	}
 
 	// Load And Inject Jaeger Envs
 	os.Setenv("JAEGER_SERVICE_NAME", fmt.Sprintf("%s%s", cfg.SystemID, cfg.getEnvOrFile("JAEGER_ENVIRONMENT")))
@@ -109,6 +211,8 @@ func Load(args []string) {
 	os.Setenv("JAEGER_SAMPLER_TYPE", cfg.getEnvOrFile("JAEGER_SAMPLER_TYPE"))
 	os.Setenv("JAEGER_SAMPLER_PARAM", cfg.getEnvOrFile("JAEGER_SAMPLER_PARAM"))
 	os.Setenv("JAEGER_REPORTER_LOG_SPANS", cfg.getEnvOrFile("JAEGER_REPORTER_LOG_SPANS"))
+
+	return nil
 }
 
 func Inject(c *Config) {
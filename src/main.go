@@ -12,11 +12,15 @@ import (
 	"logger/config"
 	"logger/models/migrations"
 	"logger/remotes/blockchain"
+	"logger/remotes/notifier"
 	"logger/remotes/postgres"
+	"logger/services"
 	"logger/web/router"
 	"logger/web/server"
 
 	"github.com/gorilla/mux"
+	"github.com/joaopandolfi/blackwhale/configurations"
+	"github.com/joaopandolfi/blackwhale/cron"
 	"github.com/joaopandolfi/blackwhale/handlers"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
 	"github.com/opentracing/opentracing-go"
@@ -25,9 +29,19 @@ import (
 )
 
 var tracerCloser io.Closer
+var cronStarted bool
 
-func configInit() {
-	config.Load(os.Args[1:])
+// fatal - log the startup error and exit, the service can not run without it
+func fatal(message string, err error) {
+	utils.CriticalError(message, err.Error())
+	os.Exit(1)
+}
+
+func configInit() *blockchain.BlockChain {
+	err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("Loading config", err)
+	}
 
 	if config.Get().SnakeByDefault {
 		handlers.ActiveSnakeCase()
@@ -38,17 +52,150 @@ func configInit() {
 	tracerCloser = closer
 	opentracing.SetGlobalTracer(tracer)
 
-	blockChainConfig := config.Get().BlockChain
-	blockchain.InitChain(blockChainConfig.PubKey)
-	blockchain.Get().SetAuth(blockChainConfig.PrivKey, blockChainConfig.Passphrase)
+	chain, err := newBlockChain()
+	if err != nil {
+		fatal("Initializing blockchain", err)
+	}
 
 	postgres.Init(config.Get())
 
-	migrations.Migrate()
-	err := migrations.Terraform()
+	err = migrations.Migrate()
+	if err != nil {
+		fatal("Migrating database", err)
+	}
+	err = migrations.Terraform(chain)
 	if err != nil {
 		utils.Error("Terraforming error", err.Error())
 	}
+
+	blockChainService := services.NewBlockChain(chain)
+
+	err = rotateBlockChainKey(blockChainService)
+	if err != nil {
+		fatal("Rotating blockchain key", err)
+	}
+
+	err = startJobs(blockChainService)
+	if err != nil {
+		fatal("Starting jobs", err)
+	}
+
+	return chain
+}
+
+// startJobs - register and start the periodic jobs
+func startJobs(blockChainService services.BlockChain) error {
+	jobs := 0
+
+	conf := config.Get().BlockChain
+	if conf.SubChains && conf.AnchorInterval > 0 {
+		err := cron.Get().AddJob("anchor", conf.AnchorInterval, services.NewAnchorJob(blockChainService))
+		if err != nil {
+			return fmt.Errorf("adding anchor job: %w", err)
+		}
+		jobs++
+	}
+
+	if interval := config.Get().Validation.Interval; interval > 0 {
+		err := cron.Get().AddJob("validation", interval, services.NewValidationJob(blockChainService, alertNotifiers()...))
+		if err != nil {
+			return fmt.Errorf("adding validation job: %w", err)
+		}
+		jobs++
+	}
+
+	if jobs == 0 {
+		return nil
+	}
+
+	cron.Get().Start()
+	cronStarted = true
+
+	return nil
+}
+
+// alertNotifiers - notifiers configured to receive the alerts, the slack of SLACK_WEBHOOK/SLACK_CHANNEL and the generic webhook
+func alertNotifiers() []notifier.Notifier {
+	conf := config.Get().Alert
+
+	notifiers := []notifier.Notifier{}
+	if slack := configurations.Configuration.SlackWebHook; len(slack) > 0 && slack[0] != "" {
+		notifiers = append(notifiers, notifier.NewSlack(slack[0], configurations.Configuration.SlackChannel))
+	}
+	if conf.Webhook != "" {
+		notifiers = append(notifiers, notifier.NewWebhook(conf.Webhook))
+	}
+	return notifiers
+}
+
+// newBlockChain - chain with the configured keys and settings, shared by the services
+// on error the chain is returned as configured until the failure
+func newBlockChain() (*blockchain.BlockChain, error) {
+	conf := config.Get().BlockChain
+
+	chain := blockchain.NewBlockChain("")
+
+	if conf.HashAlgorithm != "" {
+		err := chain.SetHashAlgorithm(conf.HashAlgorithm)
+		if err != nil {
+			return chain, fmt.Errorf("setting hash algorithm: %w", err)
+		}
+	}
+	chain.SetSignMetadata(conf.SignMetadata)
+	chain.SetValidationWorkers(config.Get().Validation.Workers)
+
+	for scheme, pubKey := range conf.VerifyKeys {
+		verifier, err := blockchain.NewVerifier(scheme, pubKey)
+		if err != nil {
+			return chain, fmt.Errorf("loading %s verify key: %w", scheme, err)
+		}
+		chain.AddVerifier(verifier)
+	}
+
+	verifier, err := blockchain.NewVerifier(conf.Scheme, conf.PubKey)
+	if err != nil {
+		return chain, fmt.Errorf("loading pubkey: %w", err)
+	}
+	chain.AddVerifier(verifier)
+
+	signer, err := blockchain.NewSigner(conf.Scheme, conf.PrivKey, conf.Passphrase)
+	if err != nil {
+		return chain, fmt.Errorf("loading privkey: %w", err)
+	}
+	chain.SetSigner(signer)
+
+	return chain, nil
+}
+
+// rotateBlockChainKey - learn the stored key rotations and rotate to the next key when configured
+func rotateBlockChainKey(blockChainService services.BlockChain) error {
+	ctx := context.Background()
+	conf := config.Get().BlockChain
+
+	err := blockChainService.LoadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("loading keys: %w", err)
+	}
+
+	if conf.NextPrivKey == "" {
+		return nil
+	}
+
+	next, err := blockchain.NewSigner(conf.NextScheme, conf.NextPrivKey, conf.NextPassphrase)
+	if err != nil {
+		return fmt.Errorf("loading next privkey: %w", err)
+	}
+
+	rotationBlock, err := blockChainService.RotateKey(ctx, next)
+	if err != nil {
+		return fmt.Errorf("rotating to %s: %w", next.Fingerprint(), err)
+	}
+
+	if rotationBlock != nil {
+		utils.Info("[BlockChain] key rotated", rotationBlock.SeqID, next.Fingerprint())
+	}
+
+	return nil
 }
 
 func gracefullShutdown() {
@@ -56,6 +203,9 @@ func gracefullShutdown() {
 	if tracerCloser != nil {
 		tracerCloser.Close()
 	}
+	if cronStarted {
+		cron.Get().GracefullShutdown()
+	}
 	// postgres.Close()
 }
 
@@ -78,14 +228,14 @@ O======================================(Nothing will be forgotten)====>
 func main() {
 	welcome()
 	//Init
-	configInit()
+	chain := configInit()
 
 	// Initialize Mux Router
 	r := mux.NewRouter()
 	r.Use(mux.CORSMethodMiddleware(r))
 
 	srv := server.New(r, config.Get())
-	nr := router.New(srv)
+	nr := router.New(srv, chain)
 	nr.Setup()
 
 	done := make(chan os.Signal, 1)
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"logger/remotes/blockchain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChainHead - last block of a chain, updated on the same transaction that appends a block
+type ChainHead struct {
+	// Empty on the global chain
+	ChainID   string    `gorm:"primarykey" json:"chain_id"`
+	BlockID   uuid.UUID `json:"block_id"`
+	Hash      string    `json:"hash"`
+	SeqID     uint      `json:"seq_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewChainHead(block *blockchain.Block) *ChainHead {
+	return &ChainHead{
+		ChainID: block.ChainID,
+		BlockID: block.ID,
+		Hash:    block.Hash,
+		SeqID:   block.SeqID,
+	}
+}
@@ -3,71 +3,360 @@ package dao
 import (
 	"context"
 	"fmt"
+	"logger/models"
 	"logger/remotes/blockchain"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/google/uuid"
 	"github.com/joaopandolfi/blackwhale/models/dao"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BlockChain interface {
 	AppendBlock(ctx context.Context, b *blockchain.Block) (*blockchain.Block, error)
-	GetSegment(init, end int) ([]blockchain.Block, error)
+	AppendBatch(ctx context.Context, b *blockchain.Block, entries []*blockchain.Entry) (*blockchain.Block, error)
+	AppendBlocks(ctx context.Context, blocks []*blockchain.Block, entries []*blockchain.Entry) ([]*blockchain.Block, error)
+	GetSegment(chainID string, init, end int) ([]blockchain.Block, error)
 	GetAll() ([]blockchain.Block, error)
+	GetKeyRotations() ([]blockchain.Block, error)
+	GetAnchors() ([]blockchain.Block, error)
+	GetLastAnchor() (*blockchain.Block, error)
+	GetChainIDs() ([]string, error)
+	GetChainHeads() ([]blockchain.Block, error)
+	GetBlock(id uuid.UUID) (*blockchain.Block, error)
+	GetBlockBySeq(chainID string, seqID uint) (*blockchain.Block, error)
+	GetBlocksPage(chainID string, fromSeqID uint, limit int) ([]blockchain.Block, error)
+	GetEntry(id uuid.UUID) (*blockchain.Entry, error)
+	GetEntries(blockID uuid.UUID) ([]blockchain.Entry, error)
+	GetBlocksBetween(chainID string, init, end uint) ([]blockchain.Block, error)
+	GetEntriesBetween(chainID string, init, end uint) ([]blockchain.Entry, error)
+	ImportBlocks(ctx context.Context, blocks []blockchain.Block, entries []blockchain.Entry) (int, error)
+	GetHead(chainID string) (*models.ChainHead, error)
+	SaveCheckpoint(ctx context.Context, checkpoint *blockchain.Checkpoint) error
+	GetCheckpoint(id uuid.UUID) (*blockchain.Checkpoint, error)
+	GetLastCheckpoint(chainID string) (*blockchain.Checkpoint, error)
+	GetCheckpoints(chainID string) ([]blockchain.Checkpoint, error)
+	SearchLogs(filter models.LogFilter) ([]models.Record, error)
+	GetByIdempotencyKeys(systemID string, keys []string) (map[string]models.Record, error)
+	RunExclusive(ctx context.Context, job string, fn func() error) (bool, error)
+	SaveValidation(ctx context.Context, result *models.ValidationResult) error
+	GetValidation(job string) (*models.ValidationResult, error)
 }
 
+const insertBatchSize = 500
+
 type blockChain struct {
-	dao dao.SQLDAO
-	mu  sync.Mutex
+	dao   dao.SQLDAO
+	chain *blockchain.BlockChain
+	// locks of the appends on this process, by chainID
+	locks *sync.Map
+}
+
+// chainLocks - *sync.Mutex by chainID shared by every dao of the process, appends on different chains do not wait each other
+var chainLocks sync.Map
+
+// NewBlockChainDao - dao chaining the new blocks with the chain keys
+func NewBlockChainDao(chain *blockchain.BlockChain) BlockChain {
+	return &blockChain{
+		dao:   new(),
+		chain: chain,
+		locks: &chainLocks,
+	}
+}
+
+// lock - lock the appends on the chain, returns the unlock
+func (s *blockChain) lock(chainID string) func() {
+	mu, _ := s.locks.LoadOrStore(chainID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// chainLockClass - first key of the advisory locks on the chain heads, the second is the chain id hash
+const chainLockClass = 0x6c6f67
+
+// inChainLock - run fn in a transaction holding the lock of the chain head
+// the postgres advisory lock serializes the appends between replicas and is released on commit or rollback
+// the local lock avoids holding connections of this replica waiting for it
+func (s *blockChain) inChainLock(chainID string, fn func(tx *gorm.DB) error) error {
+	return s.inChainsLock([]string{chainID}, fn)
+}
+
+// inChainsLock - run fn in a transaction holding the locks of the heads of all chains
+// the locks are taken sorted by chainID, so appends on crossing chains do not deadlock
+func (s *blockChain) inChainsLock(chainIDs []string, fn func(tx *gorm.DB) error) error {
+	chainIDs = append([]string{}, chainIDs...)
+	sort.Strings(chainIDs)
+
+	for _, chainID := range chainIDs {
+		defer s.lock(chainID)()
+	}
+
+	db, err := s.dao.DB()
+	if err != nil {
+		return fmt.Errorf("getting database: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, chainID := range chainIDs {
+			err := tx.Exec("select pg_advisory_xact_lock(?, hashtext(?))", chainLockClass, chainID).Error
+			if err != nil {
+				return fmt.Errorf("locking chain %s: %w", chainID, err)
+			}
+		}
+
+		return fn(tx)
+	})
+}
+
+// jobLockClass - first key of the advisory locks of the periodic jobs, the second is the job name hash
+const jobLockClass = 0x6a6f62
+
+// RunExclusive - run fn only when no other replica is running the job, returns false without running it otherwise
+// the session advisory lock is held on a dedicated connection while fn runs, so no transaction stays open
+func (s *blockChain) RunExclusive(ctx context.Context, job string, fn func() error) (bool, error) {
+	db, err := s.dao.DB()
+	if err != nil {
+		return false, fmt.Errorf("getting database: %w", err)
+	}
+
+	ran := false
+	err = db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked bool
+		err := conn.Raw("select pg_try_advisory_lock(?, hashtext(?))", jobLockClass, job).Scan(&locked).Error
+		if err != nil {
+			return fmt.Errorf("locking job %s: %w", job, err)
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("select pg_advisory_unlock(?, hashtext(?))", jobLockClass, job)
+
+		ran = true
+		return fn()
+	})
+
+	return ran, err
+}
+
+// SaveValidation - store the result of the job, replacing the previous one
+func (s *blockChain) SaveValidation(ctx context.Context, result *models.ValidationResult) error {
+	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.SaveValidation", map[string]interface{}{"job": result.Job, "status": result.Status})
+	defer tracer.Finish()
+
+	db, err := s.dao.DB()
+	if err != nil {
+		return fmt.Errorf("getting database: %w", err)
+	}
+
+	err = db.Clauses(clause.OnConflict{UpdateAll: true}).Create(result).Error
+	if err != nil {
+		return fmt.Errorf("saving validation result: %w", err)
+	}
+	return nil
+}
+
+// GetValidation - last result of the job
+func (s *blockChain) GetValidation(job string) (*models.ValidationResult, error) {
+	var results []models.ValidationResult
+
+	err := s.dao.ListConditional(&results, dao.ListParams{Limit: 1}, "job = ?", job)
+	if err != nil {
+		return nil, fmt.Errorf("getting validation result: %w", err)
+	}
+
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &results[0], nil
 }
 
-var singleton *blockChain
+// lastBlock - the head block of the chain, the genesis on empty sub-chains
+// chains stored before the heads table are scanned by seqID, imported blocks keep their original creation date
+func lastBlock(tx *gorm.DB, chainID string) (*blockchain.Block, error) {
+	var blocks []blockchain.Block
+
+	var heads []models.ChainHead
+	err := tx.Where("chain_id = ?", chainID).Limit(1).Find(&heads).Error
+	if err != nil {
+		return nil, err
+	}
 
-func NewBlockChainDao() BlockChain {
-	if singleton == nil {
-		singleton = &blockChain{
-			dao: new(),
+	if len(heads) > 0 {
+		err = tx.Where("id = ?", heads[0].BlockID).Limit(1).Find(&blocks).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			return nil, fmt.Errorf("head block %s of chain %s: %w", heads[0].BlockID, chainID, ErrNotFound)
 		}
+		return &blocks[0], nil
+	}
+
+	err = tx.Where("chain_id = ?", chainID).Order("seq_id desc").Limit(1).Find(&blocks).Error
+	if err != nil {
+		return nil, err
 	}
 
-	return singleton
+	if len(blocks) > 0 {
+		return &blocks[0], nil
+	}
+
+	if chainID == "" {
+		return &blockchain.Block{}, nil
+	}
+
+	err = tx.Where("id = ?", blockchain.GENESIS_ID_BLOCK).Limit(1).Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("genesis block: %w", ErrNotFound)
+	}
+
+	return &blocks[0], nil
+}
+
+// saveHead - move the chain head to the block, on the append transaction
+func saveHead(tx *gorm.DB, block *blockchain.Block) error {
+	err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(models.NewChainHead(block)).Error
+	if err != nil {
+		return fmt.Errorf("saving chain head: %w", err)
+	}
+	return nil
 }
 
 func (s *blockChain) AppendBlock(ctx context.Context, block *blockchain.Block) (*blockchain.Block, error) {
 	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.AppendBlock", map[string]interface{}{"id": block.ID})
 	defer tracer.Finish()
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var newValidBlock *blockchain.Block
+	err := s.inChainLock(block.ChainID, func(tx *gorm.DB) error {
+		lastBlock, err := lastBlock(tx, block.ChainID)
+		if err != nil {
+			return fmt.Errorf("recovering last block: %w", err)
+		}
+
+		newValidBlock, err = s.chain.ChainBlocks(lastBlock, block)
+		if err != nil {
+			return fmt.Errorf("adding block in to chain: %w", err)
+		}
 
-	var lastBlock blockchain.Block
-	err := s.dao.ListAll(&lastBlock, dao.ListParams{
-		Limit: 1,
-		Order: "created_at desc",
+		if err := tx.Create(newValidBlock).Error; err != nil {
+			return fmt.Errorf("saving new block: %w", err)
+		}
+
+		return saveHead(tx, newValidBlock)
 	})
 	if err != nil {
-		return nil, fmt.Errorf("recovering last block: %w", err)
+		return nil, fmt.Errorf("saving new block on database: %w", err)
 	}
 
-	newValidBlock, err := blockchain.Get().ChainBlocks(&lastBlock, block)
+	return newValidBlock, nil
+}
+
+// AppendBatch - chain a batch block and store it with its entries in a single transaction
+func (s *blockChain) AppendBatch(ctx context.Context, block *blockchain.Block, entries []*blockchain.Entry) (*blockchain.Block, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.AppendBatch", map[string]interface{}{"id": block.ID, "entries": len(entries)})
+	defer tracer.Finish()
+
+	var newValidBlock *blockchain.Block
+	err := s.inChainLock(block.ChainID, func(tx *gorm.DB) error {
+		lastBlock, err := lastBlock(tx, block.ChainID)
+		if err != nil {
+			return fmt.Errorf("recovering last block: %w", err)
+		}
+
+		newValidBlock, err = s.chain.ChainBlocks(lastBlock, block)
+		if err != nil {
+			return fmt.Errorf("adding block in to chain: %w", err)
+		}
+
+		if err := tx.Create(newValidBlock).Error; err != nil {
+			return fmt.Errorf("saving new block: %w", err)
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return fmt.Errorf("saving entries: %w", err)
+		}
+
+		return saveHead(tx, newValidBlock)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("adding block in to chain: %w", err)
+		return nil, fmt.Errorf("saving batch on database: %w", err)
 	}
 
-	err = s.dao.New(&newValidBlock)
+	return newValidBlock, nil
+}
+
+// AppendBlocks - chain the blocks, in order, and store them with the entries of the batch blocks in a single transaction
+// the blocks can be on many chains, each one is chained after the head of its chain
+func (s *blockChain) AppendBlocks(ctx context.Context, blocks []*blockchain.Block, entries []*blockchain.Entry) ([]*blockchain.Block, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.AppendBlocks", map[string]interface{}{"blocks": len(blocks), "entries": len(entries)})
+	defer tracer.Finish()
+
+	chainIDs := []string{}
+	heads := map[string]*blockchain.Block{}
+	for _, block := range blocks {
+		if _, ok := heads[block.ChainID]; !ok {
+			heads[block.ChainID] = nil
+			chainIDs = append(chainIDs, block.ChainID)
+		}
+	}
+
+	newValidBlocks := make([]*blockchain.Block, len(blocks))
+	err := s.inChainsLock(chainIDs, func(tx *gorm.DB) error {
+		for _, chainID := range chainIDs {
+			lastBlock, err := lastBlock(tx, chainID)
+			if err != nil {
+				return fmt.Errorf("recovering last block of chain %s: %w", chainID, err)
+			}
+			heads[chainID] = lastBlock
+		}
+
+		for i, block := range blocks {
+			newValidBlock, err := s.chain.ChainBlocks(heads[block.ChainID], block)
+			if err != nil {
+				return fmt.Errorf("adding block %d in to chain: %w", i, err)
+			}
+			heads[block.ChainID] = newValidBlock
+			newValidBlocks[i] = newValidBlock
+		}
+
+		if err := tx.CreateInBatches(newValidBlocks, insertBatchSize).Error; err != nil {
+			return fmt.Errorf("saving new blocks: %w", err)
+		}
+		if len(entries) > 0 {
+			if err := tx.CreateInBatches(entries, insertBatchSize).Error; err != nil {
+				return fmt.Errorf("saving entries: %w", err)
+			}
+		}
+
+		for _, chainID := range chainIDs {
+			if err := saveHead(tx, heads[chainID]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("saving new block on database: %w", err)
+		return nil, fmt.Errorf("saving %d blocks on database: %w", len(blocks), err)
 	}
 
-	return newValidBlock, nil
+	return newValidBlocks, nil
 }
 
 func (s *blockChain) GetAll() ([]blockchain.Block, error) {
 	//TODO: Do it in batches
-	return s.GetSegment(0, 0) // entire chain
+	return s.GetSegment("", 0, 0) // entire global chain
 }
 
-func (s *blockChain) GetSegment(init, end int) ([]blockchain.Block, error) {
+// GetSegment - blocks of the chain ordered by seqID, end 0 goes until the head
+func (s *blockChain) GetSegment(chainID string, init, end int) ([]blockchain.Block, error) {
 	var blocks []blockchain.Block
 
 	filters := dao.ListParams{
@@ -79,10 +368,564 @@ func (s *blockChain) GetSegment(init, end int) ([]blockchain.Block, error) {
 		filters.Offset = init
 	}
 
-	err := s.dao.ListAll(&blocks, filters)
+	err := s.dao.ListConditional(&blocks, filters, "chain_id = ?", chainID)
 	if err != nil {
 		return nil, fmt.Errorf("getting blocks: %w", err)
 	}
 
 	return blocks, nil
 }
+
+func (s *blockChain) GetKeyRotations() ([]blockchain.Block, error) {
+	var blocks []blockchain.Block
+
+	err := s.dao.ListConditional(&blocks, dao.ListParams{
+		Order: "seq_id asc",
+	}, "chain_id = '' and system_id = ?", blockchain.KEY_ROTATION_SYSTEM_ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting key rotation blocks: %w", err)
+	}
+
+	return blocks, nil
+}
+
+// GetAnchors - global chain blocks recording the sub-chain heads
+func (s *blockChain) GetAnchors() ([]blockchain.Block, error) {
+	var blocks []blockchain.Block
+
+	err := s.dao.ListConditional(&blocks, dao.ListParams{
+		Order: "seq_id asc",
+	}, "chain_id = '' and system_id = ?", blockchain.ANCHOR_SYSTEM_ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting anchor blocks: %w", err)
+	}
+
+	return blocks, nil
+}
+
+// GetLastAnchor - the newest anchor block, ErrNotFound when the heads were never anchored
+func (s *blockChain) GetLastAnchor() (*blockchain.Block, error) {
+	var block blockchain.Block
+
+	err := s.dao.ListConditional(&block, dao.ListParams{
+		Limit: 1,
+		Order: "seq_id desc",
+	}, "chain_id = '' and system_id = ?", blockchain.ANCHOR_SYSTEM_ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting last anchor block: %w", err)
+	}
+
+	if block.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &block, nil
+}
+
+// GetChainIDs - ids of the sub-chains
+func (s *blockChain) GetChainIDs() ([]string, error) {
+	db, err := s.dao.DB()
+	if err != nil {
+		return nil, fmt.Errorf("getting database: %w", err)
+	}
+
+	var chainIDs []string
+	err = db.Model(&blockchain.Block{}).Where("chain_id <> ''").Distinct().Order("chain_id").Pluck("chain_id", &chainIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting chain ids: %w", err)
+	}
+
+	return chainIDs, nil
+}
+
+// GetChainHeads - last block of each sub-chain, ordered by chainID
+// read from the stored heads, only the sub-chains stored before the heads table are scanned on the blocks
+func (s *blockChain) GetChainHeads() ([]blockchain.Block, error) {
+	db, err := s.dao.DB()
+	if err != nil {
+		return nil, fmt.Errorf("getting database: %w", err)
+	}
+
+	var heads []blockchain.Block
+	err = db.Raw("select blocks.* from chain_heads join blocks on blocks.id = chain_heads.block_id where chain_heads.chain_id <> ''").Scan(&heads).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting chain heads: %w", err)
+	}
+
+	var scanned []blockchain.Block
+	err = db.Raw("select distinct on (chain_id) * from blocks where chain_id <> '' and chain_id not in (select chain_id from chain_heads) order by chain_id, seq_id desc").Scan(&scanned).Error
+	if err != nil {
+		return nil, fmt.Errorf("scanning heads of chains without head: %w", err)
+	}
+
+	heads = append(heads, scanned...)
+	sort.Slice(heads, func(i, j int) bool { return heads[i].ChainID < heads[j].ChainID })
+
+	return heads, nil
+}
+
+func (s *blockChain) GetBlock(id uuid.UUID) (*blockchain.Block, error) {
+	var block blockchain.Block
+
+	err := s.dao.ListConditional(&block, dao.ListParams{Limit: 1}, "id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("getting block: %w", err)
+	}
+
+	if block.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &block, nil
+}
+
+// GetBlockBySeq - block of the chain on the seqID position
+func (s *blockChain) GetBlockBySeq(chainID string, seqID uint) (*blockchain.Block, error) {
+	var block blockchain.Block
+
+	err := s.dao.ListConditional(&block, dao.ListParams{Limit: 1}, "chain_id = ? and seq_id = ?", chainID, seqID)
+	if err != nil {
+		return nil, fmt.Errorf("getting block %d: %w", seqID, err)
+	}
+
+	if block.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &block, nil
+}
+
+// GetBlocksPage - up to limit blocks of the chain with seqID from fromSeqID, ordered by seqID
+// keyset paginated, the next page starts after the seqID of the last block
+func (s *blockChain) GetBlocksPage(chainID string, fromSeqID uint, limit int) ([]blockchain.Block, error) {
+	var blocks []blockchain.Block
+
+	err := s.dao.ListConditional(&blocks, dao.ListParams{
+		Order: "seq_id asc",
+		Limit: limit,
+	}, "chain_id = ? and seq_id >= ?", chainID, fromSeqID)
+	if err != nil {
+		return nil, fmt.Errorf("getting %d blocks from %d: %w", limit, fromSeqID, err)
+	}
+
+	return blocks, nil
+}
+
+func (s *blockChain) GetEntry(id uuid.UUID) (*blockchain.Entry, error) {
+	var entry blockchain.Entry
+
+	err := s.dao.ListConditional(&entry, dao.ListParams{Limit: 1}, "id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("getting entry: %w", err)
+	}
+
+	if entry.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &entry, nil
+}
+
+// GetEntries - entries of a batch block ordered by their position on the merkle tree
+func (s *blockChain) GetEntries(blockID uuid.UUID) ([]blockchain.Entry, error) {
+	var entries []blockchain.Entry
+
+	err := s.dao.ListConditional(&entries, dao.ListParams{
+		Order: `"index" asc`,
+	}, "block_id = ?", blockID)
+	if err != nil {
+		return nil, fmt.Errorf("getting entries of block %s: %w", blockID, err)
+	}
+
+	return entries, nil
+}
+
+// GetBlocksBetween - blocks of the chain with seqID in [init, end], end 0 goes until the head
+func (s *blockChain) GetBlocksBetween(chainID string, init, end uint) ([]blockchain.Block, error) {
+	var blocks []blockchain.Block
+
+	query, args := seqRange(chainID, init, end)
+	err := s.dao.ListConditional(&blocks, dao.ListParams{
+		Order: "seq_id asc",
+	}, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks [%d - %d]: %w", init, end, err)
+	}
+
+	return blocks, nil
+}
+
+// GetEntriesBetween - entries of the batch blocks of the chain with seqID in [init, end], end 0 goes until the head
+func (s *blockChain) GetEntriesBetween(chainID string, init, end uint) ([]blockchain.Entry, error) {
+	var entries []blockchain.Entry
+
+	query, args := seqRange(chainID, init, end)
+	err := s.dao.ListConditional(&entries, dao.ListParams{
+		Order: `block_id asc, "index" asc`,
+	}, fmt.Sprintf("block_id in (select id from blocks where %s)", query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting entries [%d - %d]: %w", init, end, err)
+	}
+
+	return entries, nil
+}
+
+// ImportBlocks - store already validated blocks, ordered by seqID, continuing the current head
+// blocks until the head are skipped when the head is inside them
+func (s *blockChain) ImportBlocks(ctx context.Context, blocks []blockchain.Block, entries []blockchain.Entry) (int, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.ImportBlocks", map[string]interface{}{"blocks": len(blocks), "entries": len(entries)})
+	defer tracer.Finish()
+
+	if len(blocks) == 0 {
+		return 0, nil
+	}
+
+	chainID := blocks[0].ChainID
+	for i := range blocks {
+		if blocks[i].ChainID != chainID {
+			return 0, fmt.Errorf("block %d is from chain %s, expected %s", blocks[i].SeqID, blocks[i].ChainID, chainID)
+		}
+	}
+
+	imported := 0
+	err := s.inChainLock(chainID, func(tx *gorm.DB) error {
+		head, err := lastBlock(tx, chainID)
+		if err != nil {
+			return fmt.Errorf("recovering last block: %w", err)
+		}
+
+		start := -1
+		for i := range blocks {
+			if blocks[i].SeqID != head.SeqID {
+				continue
+			}
+			if blocks[i].ID != head.ID || blocks[i].Hash != head.Hash {
+				return fmt.Errorf("block %d differs from the stored head %s", head.SeqID, head.ID)
+			}
+			start = i + 1
+			break
+		}
+
+		if start == -1 {
+			if blocks[0].LastBlockID != head.ID || blocks[0].LastBlockHash != head.Hash {
+				return fmt.Errorf("first block %d does not continue the stored head %d", blocks[0].SeqID, head.SeqID)
+			}
+			start = 0
+		}
+
+		newBlocks := blocks[start:]
+		if len(newBlocks) == 0 {
+			return nil
+		}
+
+		newBlockIDs := map[uuid.UUID]bool{}
+		for _, block := range newBlocks {
+			newBlockIDs[block.ID] = true
+		}
+
+		newEntries := []blockchain.Entry{}
+		for _, entry := range entries {
+			if newBlockIDs[entry.BlockID] {
+				newEntries = append(newEntries, entry)
+			}
+		}
+
+		if err := tx.CreateInBatches(&newBlocks, insertBatchSize).Error; err != nil {
+			return fmt.Errorf("saving blocks: %w", err)
+		}
+		if len(newEntries) > 0 {
+			if err := tx.CreateInBatches(&newEntries, insertBatchSize).Error; err != nil {
+				return fmt.Errorf("saving entries: %w", err)
+			}
+		}
+
+		imported = len(newBlocks)
+		return saveHead(tx, &newBlocks[len(newBlocks)-1])
+	})
+	if err != nil {
+		return 0, fmt.Errorf("importing on database: %w", err)
+	}
+
+	return imported, nil
+}
+
+// GetHead - the head of the chain, from the blocks when the chain has no head stored yet
+func (s *blockChain) GetHead(chainID string) (*models.ChainHead, error) {
+	db, err := s.dao.DB()
+	if err != nil {
+		return nil, fmt.Errorf("getting database: %w", err)
+	}
+
+	var heads []models.ChainHead
+	err = db.Where("chain_id = ?", chainID).Limit(1).Find(&heads).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting head of chain %s: %w", chainID, err)
+	}
+
+	if len(heads) > 0 {
+		return &heads[0], nil
+	}
+
+	block, err := lastBlock(db, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("getting last block of chain %s: %w", chainID, err)
+	}
+
+	if block.ID == uuid.Nil || block.ChainID != chainID {
+		return nil, ErrNotFound
+	}
+
+	return models.NewChainHead(block), nil
+}
+
+// SaveCheckpoint - store a validation checkpoint
+func (s *blockChain) SaveCheckpoint(ctx context.Context, checkpoint *blockchain.Checkpoint) error {
+	_, tracer := jaeger.SpanTrace(ctx, "dao.blockchain.SaveCheckpoint", map[string]interface{}{"chain": checkpoint.ChainID, "seq": checkpoint.SeqID})
+	defer tracer.Finish()
+
+	err := s.dao.New(checkpoint)
+	if err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (s *blockChain) GetCheckpoint(id uuid.UUID) (*blockchain.Checkpoint, error) {
+	var checkpoint blockchain.Checkpoint
+
+	err := s.dao.ListConditional(&checkpoint, dao.ListParams{Limit: 1}, "id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("getting checkpoint: %w", err)
+	}
+
+	if checkpoint.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &checkpoint, nil
+}
+
+// GetLastCheckpoint - checkpoint of the chain on the highest seqID
+func (s *blockChain) GetLastCheckpoint(chainID string) (*blockchain.Checkpoint, error) {
+	var checkpoint blockchain.Checkpoint
+
+	err := s.dao.ListConditional(&checkpoint, dao.ListParams{
+		Limit: 1,
+		Order: "seq_id desc, created_at desc",
+	}, "chain_id = ?", chainID)
+	if err != nil {
+		return nil, fmt.Errorf("getting last checkpoint: %w", err)
+	}
+
+	if checkpoint.ID == uuid.Nil {
+		return nil, ErrNotFound
+	}
+
+	return &checkpoint, nil
+}
+
+// GetCheckpoints - checkpoints of the chain, the newest first
+func (s *blockChain) GetCheckpoints(chainID string) ([]blockchain.Checkpoint, error) {
+	checkpoints := []blockchain.Checkpoint{}
+
+	err := s.dao.ListConditional(&checkpoints, dao.ListParams{
+		Order: "seq_id desc, created_at desc",
+	}, "chain_id = ?", chainID)
+	if err != nil {
+		return nil, fmt.Errorf("getting checkpoints: %w", err)
+	}
+
+	return checkpoints, nil
+}
+
+// logsQuery - logs stored on their own blocks and on batch entries, the blocks created by the chain are not logs
+// the payload is the same expression of the search indexes, so the predicates pushed to each side use them
+const logsQuery = `select id, block_id, created_at from (
+	select id, id as block_id, system_id, tags, log_payload(transaction_str) as payload, created_at, chain_id, seq_id from blocks where system_id not in ?
+	union all
+	select e.id, e.block_id, e.system_id, e.tags, log_payload(e.transaction_str) as payload, e.created_at, b.chain_id, b.seq_id from entries e join blocks b on b.id = e.block_id
+) logs`
+
+// searchQuery - query and args of the logs matching the filter
+// the seq range is on the chain of filter.ChainID, the seq ids of different chains overlap
+func searchQuery(filter models.LogFilter) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{[]string{
+		blockchain.GENESIS_SYSTEM_ID,
+		blockchain.KEY_ROTATION_SYSTEM_ID,
+		blockchain.BATCH_SYSTEM_ID,
+		blockchain.ANCHOR_SYSTEM_ID,
+	}}
+
+	where := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if filter.SystemID != "" {
+		where("system_id = ?", filter.SystemID)
+	}
+	for _, tag := range filter.Tags {
+		where("? = any(string_to_array(tags, ?))", tag, models.TAG_SEPARATOR)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at <= ?", filter.To)
+	}
+	if filter.FromSeq > 0 || filter.ToSeq > 0 {
+		where("chain_id = ?", filter.ChainID)
+	}
+	if filter.FromSeq > 0 {
+		where("seq_id >= ?", filter.FromSeq)
+	}
+	if filter.ToSeq > 0 {
+		where("seq_id <= ?", filter.ToSeq)
+	}
+	for _, path := range filter.Paths {
+		where("payload @@ cast(? as jsonpath)", path)
+	}
+	if filter.After != nil {
+		where("(created_at, id) > (?, ?)", filter.After.CreatedAt, filter.After.ID)
+	}
+
+	query := logsQuery
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by created_at asc, id asc limit ?"
+	args = append(args, filter.Limit)
+
+	return query, args
+}
+
+// SearchLogs - logs matching the filter ordered by creation date and id, up to filter.Limit after the filter cursor
+// the records have the stored block and entry, not unpacked
+func (s *blockChain) SearchLogs(filter models.LogFilter) ([]models.Record, error) {
+	db, err := s.dao.DB()
+	if err != nil {
+		return nil, fmt.Errorf("getting database: %w", err)
+	}
+
+	query, args := searchQuery(filter)
+
+	var rows []struct {
+		ID      uuid.UUID
+		BlockID uuid.UUID
+	}
+	err = db.Raw(query, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("searching logs: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return []models.Record{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	blockIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+		blockIDs[i] = row.BlockID
+	}
+
+	var blocks []blockchain.Block
+	err = db.Where("id in ?", blockIDs).Find(&blocks).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks of the logs: %w", err)
+	}
+
+	var entries []blockchain.Entry
+	err = db.Where("id in ?", ids).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting entries of the logs: %w", err)
+	}
+
+	blocksByID := make(map[uuid.UUID]*blockchain.Block, len(blocks))
+	for i := range blocks {
+		blocksByID[blocks[i].ID] = &blocks[i]
+	}
+
+	entriesByID := make(map[uuid.UUID]*blockchain.Entry, len(entries))
+	for i := range entries {
+		entriesByID[entries[i].ID] = &entries[i]
+	}
+
+	records := make([]models.Record, len(rows))
+	for i, row := range rows {
+		records[i] = models.Record{
+			Block: blocksByID[row.BlockID],
+			Entry: entriesByID[row.ID],
+		}
+		if records[i].Block == nil {
+			return nil, fmt.Errorf("block %s of log %s: %w", row.BlockID, row.ID, ErrNotFound)
+		}
+	}
+
+	return records, nil
+}
+
+// GetByIdempotencyKeys - logs of the system already stored with the keys, on their own blocks or on batch entries
+// the records are by key and have the stored block and entry, not unpacked
+func (s *blockChain) GetByIdempotencyKeys(systemID string, keys []string) (map[string]models.Record, error) {
+	records := map[string]models.Record{}
+	if len(keys) == 0 {
+		return records, nil
+	}
+
+	var entries []blockchain.Entry
+	err := s.dao.ListConditional(&entries, dao.ListParams{}, "system_id = ? and idempotency_key in ?", systemID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("getting entries by idempotency key: %w", err)
+	}
+
+	var blocks []blockchain.Block
+	err = s.dao.ListConditional(&blocks, dao.ListParams{}, "system_id = ? and idempotency_key in ?", systemID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks by idempotency key: %w", err)
+	}
+
+	for i := range blocks {
+		records[blocks[i].IdempotencyKey] = models.Record{Block: &blocks[i]}
+	}
+
+	if len(entries) == 0 {
+		return records, nil
+	}
+
+	blockIDs := make([]uuid.UUID, len(entries))
+	for i := range entries {
+		blockIDs[i] = entries[i].BlockID
+	}
+
+	var batchBlocks []blockchain.Block
+	err = s.dao.ListConditional(&batchBlocks, dao.ListParams{}, "id in ?", blockIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks of the entries: %w", err)
+	}
+
+	blocksByID := make(map[uuid.UUID]*blockchain.Block, len(batchBlocks))
+	for i := range batchBlocks {
+		blocksByID[batchBlocks[i].ID] = &batchBlocks[i]
+	}
+
+	for i := range entries {
+		block, ok := blocksByID[entries[i].BlockID]
+		if !ok {
+			return nil, fmt.Errorf("block %s of entry %s: %w", entries[i].BlockID, entries[i].ID, ErrNotFound)
+		}
+		records[entries[i].IdempotencyKey] = models.Record{Block: block, Entry: &entries[i]}
+	}
+
+	return records, nil
+}
+
+// seqRange - condition of the chain blocks with seqID in [init, end], end 0 has no upper bound
+func seqRange(chainID string, init, end uint) (string, []interface{}) {
+	if end == 0 {
+		return "chain_id = ? and seq_id >= ?", []interface{}{chainID, init}
+	}
+	return "chain_id = ? and seq_id between ? and ?", []interface{}{chainID, init, end}
+}
@@ -0,0 +1,185 @@
+package dao
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"logger/config"
+	"logger/models/migrations"
+	"logger/remotes/blockchain"
+	"logger/remotes/postgres"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// _testDao - dao on the empty database of LOGGER_TEST_POSTGRES, the test is skipped when it is not set
+func _testDao(t *testing.T) *blockChain {
+	dsn := os.Getenv("LOGGER_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("LOGGER_TEST_POSTGRES is not set")
+	}
+
+	err := postgres.Init(config.Config{PostgreSQL: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrations.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = postgres.Driver().Exec("truncate blocks, entries, chain_heads, checkpoints").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(blockchain.NewEd25519Signer(priv))
+	chain.AddVerifier(blockchain.NewEd25519Verifier(pub))
+
+	err = migrations.Terraform(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBlockChainDao(chain).(*blockChain)
+}
+
+func TestAppendBlockHead(t *testing.T) {
+	s := _testDao(t)
+	ctx := context.Background()
+
+	block := blockchain.NewBlock("sauron", map[string]interface{}{"n": 1})
+	block.ChainID = "sauron"
+	stored, err := s.AppendBlock(ctx, block)
+	assert.Nil(t, err)
+
+	head, err := s.GetHead("sauron")
+	assert.Nil(t, err)
+	assert.Equal(t, stored.ID, head.BlockID)
+	assert.Equal(t, stored.Hash, head.Hash)
+
+	heads, err := s.GetChainHeads()
+	assert.Nil(t, err)
+	assert.Len(t, heads, 1)
+	assert.Equal(t, stored.ID, heads[0].ID)
+
+	// a batch failing to store its entries rolls back the block and its head
+	entry := blockchain.NewEntry("sauron", map[string]interface{}{"n": 2})
+	assert.Nil(t, entry.HashEntry())
+	batch, err := blockchain.NewBatchBlock([]*blockchain.Entry{entry, entry})
+	assert.Nil(t, err)
+	batch.ChainID = "sauron"
+
+	_, err = s.AppendBatch(ctx, batch, []*blockchain.Entry{entry, entry})
+	assert.NotNil(t, err)
+
+	head, err = s.GetHead("sauron")
+	assert.Nil(t, err)
+	assert.Equal(t, stored.ID, head.BlockID)
+	assert.Equal(t, stored.SeqID, head.SeqID)
+
+	_, err = s.GetBlock(batch.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the next append chains on the head
+	block = blockchain.NewBlock("sauron", map[string]interface{}{"n": 3})
+	block.ChainID = "sauron"
+	next, err := s.AppendBlock(ctx, block)
+	assert.Nil(t, err)
+	assert.Equal(t, stored.ID, next.LastBlockID)
+	assert.Equal(t, stored.SeqID+1, next.SeqID)
+
+	head, err = s.GetHead("sauron")
+	assert.Nil(t, err)
+	assert.Equal(t, next.ID, head.BlockID)
+	assert.NotEqual(t, uuid.Nil, head.BlockID)
+}
+
+func TestGetChainHeadsWithoutHead(t *testing.T) {
+	s := _testDao(t)
+
+	block := blockchain.NewBlock("gandalf", map[string]interface{}{"n": 1})
+	block.ChainID = "gandalf"
+	stored, err := s.AppendBlock(context.Background(), block)
+	assert.Nil(t, err)
+
+	// chains stored before the heads table have no head row
+	err = postgres.Driver().Exec("delete from chain_heads where chain_id = ?", "gandalf").Error
+	assert.Nil(t, err)
+
+	heads, err := s.GetChainHeads()
+	assert.Nil(t, err)
+	assert.Len(t, heads, 1)
+	assert.Equal(t, stored.ID, heads[0].ID)
+}
+
+func TestConcurrentAppendsBetweenDaos(t *testing.T) {
+	s := _testDao(t)
+	// another replica, its appends are not serialized by the locks of this process
+	replica := &blockChain{dao: new(), chain: s.chain, locks: &sync.Map{}}
+
+	const appends = 20
+	var wg sync.WaitGroup
+	for i := 0; i < appends; i++ {
+		for _, d := range []*blockChain{s, replica} {
+			wg.Add(1)
+			go func(d *blockChain, i int) {
+				defer wg.Done()
+				block := blockchain.NewBlock("sauron", map[string]interface{}{"n": i})
+				block.ChainID = "sauron"
+				_, err := d.AppendBlock(context.Background(), block)
+				assert.Nil(t, err)
+			}(d, i)
+		}
+	}
+	wg.Wait()
+
+	blocks, err := s.GetBlocksPage("sauron", 0, 2*appends+1)
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 2*appends)
+
+	// the seq ids are unique and contiguous, each block chained to the previous one
+	last := blockchain.Block{ID: uuid.MustParse(blockchain.GENESIS_ID_BLOCK)}
+	for i, block := range blocks {
+		assert.Equal(t, uint(i+1), block.SeqID)
+		assert.Equal(t, last.ID, block.LastBlockID)
+		last = block
+	}
+
+	head, err := s.GetHead("sauron")
+	assert.Nil(t, err)
+	assert.Equal(t, last.ID, head.BlockID)
+}
+
+func TestForkedBlockRejected(t *testing.T) {
+	s := _testDao(t)
+
+	block := blockchain.NewBlock("sauron", map[string]interface{}{"n": 1})
+	block.ChainID = "sauron"
+	stored, err := s.AppendBlock(context.Background(), block)
+	assert.Nil(t, err)
+
+	// a block inserted without the append, chained to the same parent
+	forged := *stored
+	forged.ID = uuid.New()
+	forged.SeqID = stored.SeqID + 1
+	err = postgres.Driver().Create(&forged).Error
+	assert.NotNil(t, err)
+
+	// or on the same seq id
+	forged.ID = uuid.New()
+	forged.SeqID = stored.SeqID
+	forged.LastBlockID = stored.ID
+	err = postgres.Driver().Create(&forged).Error
+	assert.NotNil(t, err)
+
+	blocks, err := s.GetBlocksPage("sauron", 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 1)
+}
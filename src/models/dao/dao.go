@@ -1,11 +1,15 @@
 package dao
 
 import (
+	"errors"
 	"logger/remotes/postgres"
 
 	"github.com/joaopandolfi/blackwhale/models/dao"
 )
 
+// ErrNotFound - returned when the searched record does not exist
+var ErrNotFound = errors.New("not found")
+
 func new() dao.SQLDAO {
 	return dao.Sql(postgres.Driver())
 }
@@ -0,0 +1,36 @@
+package dao
+
+import (
+	"logger/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	query, args := searchQuery(models.LogFilter{Limit: 10})
+	assert.NotContains(t, query, "logs where")
+	assert.True(t, strings.HasSuffix(query, "order by created_at asc, id asc limit ?"))
+	assert.Len(t, args, 2)
+	assert.Equal(t, 10, args[1])
+
+	query, args = searchQuery(models.LogFilter{
+		SystemID: "sauron",
+		ChainID:  "sauron",
+		FromSeq:  3,
+		ToSeq:    7,
+		Paths:    []string{`$.user == "bob"`},
+		Limit:    10,
+	})
+	assert.Contains(t, query, "logs where system_id = ? and chain_id = ? and seq_id >= ? and seq_id <= ? and payload @@ cast(? as jsonpath) ")
+	assert.Equal(t, []interface{}{"sauron", "sauron", uint(3), uint(7), `$.user == "bob"`, 10}, args[1:])
+
+	// the seq range of the global chain is still scoped by its empty chain id
+	query, args = searchQuery(models.LogFilter{ToSeq: 7, Limit: 10})
+	assert.Contains(t, query, "logs where chain_id = ? and seq_id <= ? ")
+	assert.Equal(t, []interface{}{"", uint(7), 10}, args[1:])
+
+	// the placeholders match the args
+	assert.Equal(t, strings.Count(query, "?"), len(args))
+}
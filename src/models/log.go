@@ -14,9 +14,46 @@ type Log struct {
 	Payload  map[string]interface{}
 	SystemID string
 	Tags     string
-	Block    *blockchain.Block
+	// IdempotencyKey sent by the client, a retry with the same key returns the stored log
+	IdempotencyKey string
+	Block          *blockchain.Block
+	// Entry its setted when the log was sealed with others in a batch block
+	Entry *blockchain.Entry
+	// Replayed its setted when the log was already stored with the idempotency key
+	Replayed bool
 }
 
 func (m *Log) ParseTags() []string {
 	return strings.Split(m.Tags, TAG_SEPARATOR)
 }
+
+// Record - a stored log read back, with the block that holds it
+type Record struct {
+	Block *blockchain.Block `json:"block"`
+	// Entry its setted when the log was sealed with others in a batch block
+	Entry *blockchain.Entry `json:"entry,omitempty"`
+	// Transaction and tags of the log, from the entry on batch blocks
+	Transaction map[string]interface{} `json:"transaction"`
+	Tags        []string               `json:"tags"`
+	Signature   string                 `json:"signature"`
+	// Verification its setted when the record was verified on the read
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// Verification - result of verifying a single record
+type Verification struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// BulkResult - result of a log of a bulk request, by its position on the request
+type BulkResult struct {
+	Index int `json:"index"`
+	// ID of the log and BlockID of the block holding it, the same block when the log is not on a batch
+	ID      *uuid.UUID `json:"id,omitempty"`
+	BlockID *uuid.UUID `json:"block_id,omitempty"`
+	SeqID   uint       `json:"seq_id,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	// Replayed its setted when the log was already stored with the idempotency key
+	Replayed bool `json:"replayed,omitempty"`
+}
@@ -2,24 +2,87 @@ package migrations
 
 import (
 	"fmt"
+	"logger/models"
 	"logger/remotes/blockchain"
 	"logger/remotes/postgres"
 
 	"github.com/google/uuid"
 	"github.com/joaopandolfi/blackwhale/utils"
+	"gorm.io/gorm/clause"
 )
 
-func Migrate() {
+// Migrate - create and update the tables, a failure must stop the service
+// the unique indexes of the blocks are not created on a database with forked blocks, it must be fixed before
+func Migrate() error {
 	if postgres.Driver() == nil {
-		utils.CriticalError("[Migrations] - Database is not connected")
-		return
+		return fmt.Errorf("database is not connected")
 	}
-	postgres.Driver().AutoMigrate(
+
+	err := postgres.Driver().AutoMigrate(
 		&blockchain.Block{},
+		&blockchain.Entry{},
+		&models.ChainHead{},
+		&blockchain.Checkpoint{},
+		&models.ValidationResult{},
 	)
+	if err != nil {
+		return fmt.Errorf("migrating tables, forked blocks are reported by GET /validate/report: %w", err)
+	}
+
+	err = searchIndexes()
+	if err != nil {
+		utils.CriticalError("[Migrations] - Creating search indexes", err.Error())
+	}
+
+	return nil
 }
 
-func Terraform() error {
+// payloadFunction - jsonb of a stored transaction, null when it is not valid jsonb (like strings with \u0000)
+// immutable so it can be indexed, the search calls it with the same expression to use the index
+const payloadFunction = `create or replace function log_payload(transaction text) returns jsonb
+language plpgsql immutable parallel safe as $$
+begin
+	return transaction::jsonb;
+exception when others then
+	return null;
+end;
+$$`
+
+// searchIndexes - expression indexes on the jsonb of the transactions, to search by payload fields
+// the jsonb is not stored, so the indexes are built without rewriting the tables
+func searchIndexes() error {
+	err := postgres.Driver().Exec(payloadFunction).Error
+	if err != nil {
+		return fmt.Errorf("creating payload function: %w", err)
+	}
+
+	for _, table := range []string{"blocks", "entries"} {
+		// generated column of the previous versions, dropping it does not rewrite the table
+		err = postgres.Driver().Exec(fmt.Sprintf("alter table %s drop column if exists payload", table)).Error
+		if err != nil {
+			return fmt.Errorf("dropping payload column of %s: %w", table, err)
+		}
+
+		err = postgres.Driver().Exec(fmt.Sprintf(
+			"create index concurrently if not exists idx_%s_payload on %s using gin (log_payload(transaction_str) jsonb_path_ops)", table, table,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("indexing payload of %s: %w", table, err)
+		}
+
+		err = postgres.Driver().Exec(fmt.Sprintf(
+			"create index if not exists idx_%s_created_at on %s (created_at, id)", table, table,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("indexing creation date of %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// Terraform - store the genesis signed by the chain when the database has no blocks
+func Terraform(chain *blockchain.BlockChain) error {
 	if postgres.Driver() == nil {
 		utils.CriticalError("[Terraform] - Database is not connected")
 		return fmt.Errorf("[Terraform] - Database is not connected")
@@ -30,20 +93,26 @@ func Terraform() error {
 	postgres.Driver().First(&genesisBlock)
 	if genesisBlock.ID == uuid.Nil {
 
-		err := blockchain.Get().GenerateGenesis()
+		err := chain.GenerateGenesis()
 		if err != nil {
 			msg := "[Terraform] - Creating genesis block"
 			utils.CriticalError(msg, err.Error())
 			return fmt.Errorf("%s : %w", msg, err)
 		}
 
-		genesisBlock = blockchain.Get().GenesisBlock
+		genesisBlock = chain.GenesisBlock
 
-		tx := postgres.Driver().Create(&genesisBlock)
+		// other replica can be terraforming at the same time
+		tx := postgres.Driver().Clauses(clause.OnConflict{DoNothing: true}).Create(&genesisBlock)
 
 		if tx.Error != nil {
 			return fmt.Errorf("terraforming genesis block: %w", tx.Error)
 		}
+
+		tx = postgres.Driver().Clauses(clause.OnConflict{DoNothing: true}).Create(models.NewChainHead(&genesisBlock))
+		if tx.Error != nil {
+			return fmt.Errorf("terraforming genesis head: %w", tx.Error)
+		}
 	}
 
 	return nil
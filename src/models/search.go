@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"logger/remotes/blockchain"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogFilter - conditions of the logs search, the zero values are not filtered
+type LogFilter struct {
+	SystemID string
+	// Tags the log must have, all of them
+	Tags []string
+	// Created at range, inclusive
+	From time.Time
+	To   time.Time
+	// SeqID range of the block holding the log, inclusive
+	FromSeq uint
+	ToSeq   uint
+	// ChainID of the seq range, set by the service from the system
+	ChainID string
+	// Postgres jsonpath predicates on the transaction payload, like $.user == "bob"
+	Paths []string
+
+	// After its the cursor of the last log of the previous page
+	After *LogCursor
+	Limit int
+}
+
+// LogCursor - position of a log on the search, ordered by creation date and id
+type LogCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String - opaque form of the cursor sent to the clients
+func (c *LogCursor) String() string {
+	raw := fmt.Sprintf("%s|%s", c.CreatedAt.UTC().Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseLogCursor - read the cursor sent by a client
+func ParseLogCursor(cursor string) (*LogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("decoding cursor: %w", err)
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	c := &LogCursor{}
+	c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cursor date: %w", err)
+	}
+
+	c.ID, err = uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cursor id: %w", err)
+	}
+
+	return c, nil
+}
+
+// LogPage - page of the logs search, Next its empty on the last page
+type LogPage struct {
+	Logs []Record `json:"logs"`
+	Next string   `json:"next,omitempty"`
+}
+
+// BlockPage - page of the chain blocks ordered by seqID
+// Next its the after_seq of the next page, the last block returned, nil when the page is empty
+type BlockPage struct {
+	Blocks []blockchain.Block `json:"blocks"`
+	Next   *uint              `json:"next,omitempty"`
+	More   bool               `json:"more"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLogCursor(t *testing.T) {
+	cursor := &LogCursor{
+		CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	parsed, err := ParseLogCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("parsing cursor: %s", err)
+	}
+
+	if !parsed.CreatedAt.Equal(cursor.CreatedAt) || parsed.ID != cursor.ID {
+		t.Errorf("expected %v got: %v", cursor, parsed)
+	}
+
+	_, err = ParseLogCursor("not a cursor")
+	if err == nil {
+		t.Errorf("expected error parsing an invalid cursor")
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Status of a background validation
+const (
+	ValidationRunning = "running"
+	ValidationValid   = "valid"
+	ValidationInvalid = "invalid"
+)
+
+// ValidationResult - last background validation, stored so every replica reports the same result
+type ValidationResult struct {
+	// Job validating, one result by job
+	Job    string `gorm:"primarykey" json:"-"`
+	Status string `json:"status"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	// Replica running the validation
+	Replica   string    `json:"replica"`
+	StartedAt time.Time `json:"started_at"`
+	// Empty while running
+	Duration  string    `json:"duration,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+const ANCHOR_SYSTEM_ID = "anchor"
+const TRANSACTION_CODE_ANCHORS = "anchors"
+
+// AnchorHead - head of a sub-chain recorded on the global chain
+type AnchorHead struct {
+	SeqID uint      `json:"seq_id"`
+	ID    uuid.UUID `json:"id"`
+	Hash  string    `json:"hash"`
+}
+
+// NewAnchorBlock - create the global chain block recording the heads of the sub-chains
+func NewAnchorBlock(heads []Block) *Block {
+	anchors := map[string]interface{}{}
+	for _, head := range heads {
+		anchors[head.ChainID] = AnchorHead{
+			SeqID: head.SeqID,
+			ID:    head.ID,
+			Hash:  head.Hash,
+		}
+	}
+
+	return NewBlock(ANCHOR_SYSTEM_ID, map[string]interface{}{
+		TRANSACTION_CODE_ANCHORS: anchors,
+	})
+}
+
+// Anchors - read the sub-chain heads from the signed payload
+func (b *Block) Anchors() (map[string]AnchorHead, error) {
+	var payload struct {
+		SystemID string                `json:"system_id"`
+		Anchors  map[string]AnchorHead `json:"anchors"`
+	}
+
+	err := json.Unmarshal([]byte(b.TransactionStr), &payload)
+	if err != nil {
+		return nil, fmt.Errorf("parsing transaction: %w", err)
+	}
+
+	if payload.SystemID != ANCHOR_SYSTEM_ID {
+		return nil, fmt.Errorf("block is not an anchor")
+	}
+
+	return payload.Anchors, nil
+}
+
+// CheckAnchors - check the sub-chain blocks, ordered by seqID, against the heads recorded by the anchor blocks
+// toHead tells the blocks go until the sub-chain head, so no anchored block can be missing after them
+func (b *BlockChain) CheckAnchors(chainID string, blocks []Block, anchorBlocks []Block, toHead bool) error {
+	checker, err := b.NewAnchorChecker(chainID, anchorBlocks)
+	if err != nil {
+		return err
+	}
+	return checker.Check(blocks, toHead)
+}
+
+type anchoredHead struct {
+	AnchorHead
+	anchorSeqID uint
+}
+
+// AnchorChecker - check the batches of a sub-chain against its anchored heads
+// the anchor blocks are verified once, when the checker is created
+type AnchorChecker struct {
+	chainID string
+	// newest anchors first, they cover the most blocks
+	heads []anchoredHead
+}
+
+// NewAnchorChecker - verify the anchor blocks and keep the heads recorded for the sub-chain
+func (b *BlockChain) NewAnchorChecker(chainID string, anchorBlocks []Block) (*AnchorChecker, error) {
+	sorted := append([]Block{}, anchorBlocks...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SeqID > sorted[j].SeqID
+	})
+
+	checker := &AnchorChecker{chainID: chainID}
+	for i := range sorted {
+		anchorBlock := &sorted[i]
+
+		err := b.validateBlock(anchorBlock)
+		if err != nil {
+			return nil, fmt.Errorf("validating anchor block %d: %w", anchorBlock.SeqID, err)
+		}
+
+		anchors, err := anchorBlock.Anchors()
+		if err != nil {
+			return nil, fmt.Errorf("reading anchor block %d: %w", anchorBlock.SeqID, err)
+		}
+
+		head, ok := anchors[chainID]
+		if !ok {
+			continue
+		}
+		checker.heads = append(checker.heads, anchoredHead{AnchorHead: head, anchorSeqID: anchorBlock.SeqID})
+	}
+
+	return checker, nil
+}
+
+// Check - check the blocks, ordered by seqID, against the anchored heads in their range
+// toHead tells the blocks go until the sub-chain head, so no anchored block can be missing after them
+func (c *AnchorChecker) Check(blocks []Block, toHead bool) error {
+	if len(blocks) == 0 && !toHead {
+		return nil
+	}
+
+	bySeq := map[uint]*Block{}
+	for i := range blocks {
+		bySeq[blocks[i].SeqID] = &blocks[i]
+	}
+
+	var first, last uint
+	if len(blocks) > 0 {
+		first, last = blocks[0].SeqID, blocks[len(blocks)-1].SeqID
+	}
+
+	for _, head := range c.heads {
+		if toHead && (len(blocks) == 0 || head.SeqID > last) {
+			return fmt.Errorf("block %d anchored on %d is missing from chain %s", head.SeqID, head.anchorSeqID, c.chainID)
+		}
+
+		if head.SeqID < first || head.SeqID > last {
+			continue
+		}
+
+		block := bySeq[head.SeqID]
+		if block == nil || block.ID != head.ID || block.Hash != head.Hash {
+			return fmt.Errorf("block %d of chain %s differs from the anchored on %d [%s]", head.SeqID, c.chainID, head.anchorSeqID, head.Hash)
+		}
+	}
+
+	return nil
+}
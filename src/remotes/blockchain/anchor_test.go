@@ -0,0 +1,129 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func _mockSubChain(t *testing.T, chain *blockchain.BlockChain, chainID string, size int) []blockchain.Block {
+	blocks := []blockchain.Block{chain.GenesisBlock}
+	for i := 0; i < size; i++ {
+		block := blockchain.NewBlock(chainID, map[string]interface{}{"i": i})
+		block.ChainID = chainID
+
+		last := blocks[len(blocks)-1]
+		newBlock, err := chain.ChainBlocks(&last, block)
+		assert.Nil(t, err)
+		blocks = append(blocks, *newBlock)
+	}
+	return blocks
+}
+
+func TestSubChains(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	genesis := chain.GenesisBlock
+
+	sauron := _mockSubChain(t, chain, "sauron", 3)
+	gandalf := _mockSubChain(t, chain, "gandalf", 2)
+	assert.Equal(t, uint(1), sauron[1].SeqID)
+	assert.Equal(t, uint(1), gandalf[1].SeqID)
+	assert.Equal(t, genesis.Hash, sauron[1].LastBlockHash)
+
+	err = chain.ValidateBlocks(append([]blockchain.Block{}, sauron...))
+	assert.Nil(t, err)
+
+	// a block can not be moved to another sub-chain
+	moved := append([]blockchain.Block{}, sauron[:2]...)
+	moved = append(moved, gandalf[2])
+	moved[2].LastBlockID, moved[2].LastBlockHash = moved[1].ID, moved[1].Hash
+	err = chain.ValidateBlocks(moved)
+	assert.Error(t, err)
+
+	// the global chain anchors the sub-chain heads
+	chain.GenesisBlock = genesis
+	chain.Chain = []blockchain.Block{genesis}
+	anchorBlock, err := chain.AppendBlock(blockchain.NewAnchorBlock([]blockchain.Block{sauron[2], gandalf[2]}))
+	assert.Nil(t, err)
+	anchors := []blockchain.Block{*anchorBlock}
+
+	heads, err := anchorBlock.Anchors()
+	assert.Nil(t, err)
+	assert.Equal(t, sauron[2].Hash, heads["sauron"].Hash)
+
+	err = chain.CheckAnchors("sauron", sauron, anchors, true)
+	assert.Nil(t, err)
+
+	// the anchored block was rewritten
+	forged := append([]blockchain.Block{}, sauron...)
+	forged[2].Hash = forged[1].Hash
+	err = chain.CheckAnchors("sauron", forged, anchors, true)
+	assert.ErrorContains(t, err, "differs from the anchored")
+
+	// the chain was truncated before the anchored head
+	err = chain.CheckAnchors("sauron", sauron[:2], anchors, true)
+	assert.ErrorContains(t, err, "is missing from chain")
+
+	err = chain.CheckAnchors("sauron", sauron[:2], anchors, false)
+	assert.Nil(t, err)
+}
+
+func TestSubChainRetiredKey(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	genesis := chain.GenesisBlock
+
+	next, _ := _generateMockECDSA()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	sauron := _mockSubChain(t, chain, "sauron", 2)
+	assert.Equal(t, next.Fingerprint(), sauron[1].KeyFingerprint)
+
+	// the retired key keeps signing on the sub-chain
+	chain.SetSigner(signer)
+	last := sauron[len(sauron)-1]
+	block := blockchain.NewBlock("sauron", map[string]interface{}{"i": 3})
+	block.ChainID = "sauron"
+	retired, err := chain.ChainBlocks(&last, block)
+	assert.Nil(t, err)
+
+	chain.GenesisBlock = genesis
+	err = chain.ValidateBlocks(append(sauron, *retired))
+	assert.ErrorContains(t, err, "block signed by a retired key")
+}
+
+func TestSubChainRetiredKeyFirstBlock(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	genesis := chain.GenesisBlock
+
+	next, _ := _generateMockECDSA()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	// the first block of the sub-chain is signed by the key retired before it
+	chain.SetSigner(signer)
+	sauron := _mockSubChain(t, chain, "sauron", 1)
+
+	chain.GenesisBlock = genesis
+	err = chain.ValidateBlocks(sauron)
+	assert.ErrorContains(t, err, "block signed by a retired key")
+}
@@ -0,0 +1,323 @@
+package blockchain
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ARCHIVE_FORMAT_VERSION - increment when the archive layout changes
+// 2 splits the blocks and entries in pages, with the manifest after them
+const ARCHIVE_FORMAT_VERSION = 2
+
+const (
+	archiveManifestFile = "manifest.json"
+	archiveBlocksFile   = "blocks"
+	archiveEntriesFile  = "entries"
+	archivePageExt      = ".ndjson"
+)
+
+// ArchiveKey - public key used to sign blocks of the archive
+type ArchiveKey struct {
+	Scheme      string
+	Fingerprint string
+	PublicKey   string
+}
+
+// ArchiveManifest - describes the content of an archive
+type ArchiveManifest struct {
+	FormatVersion int
+	CreatedAt     time.Time
+
+	FirstSeqID uint
+	LastSeqID  uint
+	Blocks     int
+	Entries    int
+
+	HeadID   uuid.UUID
+	HeadHash string
+
+	PublicKeys []ArchiveKey
+}
+
+// Archive - a portable segment of the chain
+// a gzip tar with the manifest, the blocks and the batch entries as ndjson
+type Archive struct {
+	Manifest ArchiveManifest
+	Blocks   []Block
+	Entries  []Entry
+}
+
+// NewArchive - describe the blocks, ordered by seqID, and the keys that can verify them
+func NewArchive(blocks []Block, entries []Entry, keys []Verifier) *Archive {
+	manifest := ArchiveManifest{
+		FormatVersion: ARCHIVE_FORMAT_VERSION,
+		CreatedAt:     time.Now(),
+		Blocks:        len(blocks),
+		Entries:       len(entries),
+	}
+
+	if len(blocks) > 0 {
+		head := blocks[len(blocks)-1]
+		manifest.FirstSeqID = blocks[0].SeqID
+		manifest.LastSeqID = head.SeqID
+		manifest.HeadID = head.ID
+		manifest.HeadHash = head.Hash
+	}
+
+	for _, key := range keys {
+		manifest.PublicKeys = append(manifest.PublicKeys, ArchiveKey{
+			Scheme:      key.Scheme(),
+			Fingerprint: key.Fingerprint(),
+			PublicKey:   key.PublicKey(),
+		})
+	}
+
+	return &Archive{
+		Manifest: manifest,
+		Blocks:   blocks,
+		Entries:  entries,
+	}
+}
+
+// Write - encode the archive as a single page
+func (a *Archive) Write(w io.Writer) error {
+	aw := &ArchiveWriter{
+		gz:       gzip.NewWriter(w),
+		manifest: a.Manifest,
+	}
+	aw.tw = tar.NewWriter(aw.gz)
+
+	err := aw.writePage(a.Blocks, a.Entries)
+	if err != nil {
+		return err
+	}
+
+	return aw.close()
+}
+
+// ArchiveWriter - encode an archive page by page, keeping only the page on memory
+// the manifest describes the pages and is written on Close
+type ArchiveWriter struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	manifest ArchiveManifest
+	pages    int
+}
+
+// NewArchiveWriter - archive written on w, with the keys that can verify the blocks
+func NewArchiveWriter(w io.Writer, keys []Verifier) *ArchiveWriter {
+	gz := gzip.NewWriter(w)
+	return &ArchiveWriter{
+		gz:       gz,
+		tw:       tar.NewWriter(gz),
+		manifest: NewArchive(nil, nil, keys).Manifest,
+	}
+}
+
+// WritePage - append the blocks, ordered by seqID after the previous pages, and the entries of their batch blocks
+func (a *ArchiveWriter) WritePage(blocks []Block, entries []Entry) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	if a.manifest.Blocks == 0 {
+		a.manifest.FirstSeqID = blocks[0].SeqID
+	}
+	head := blocks[len(blocks)-1]
+	a.manifest.LastSeqID = head.SeqID
+	a.manifest.HeadID = head.ID
+	a.manifest.HeadHash = head.Hash
+	a.manifest.Blocks += len(blocks)
+	a.manifest.Entries += len(entries)
+
+	return a.writePage(blocks, entries)
+}
+
+// Close - write the manifest and flush the archive, the underlying writer is not closed
+func (a *ArchiveWriter) Close() error {
+	return a.close()
+}
+
+func (a *ArchiveWriter) writePage(blocks []Block, entries []Entry) error {
+	var blocksData bytes.Buffer
+	for i := range blocks {
+		if err := WriteBlock(&blocksData, &blocks[i]); err != nil {
+			return err
+		}
+	}
+
+	var entriesData bytes.Buffer
+	encoder := json.NewEncoder(&entriesData)
+	for i := range entries {
+		if err := encoder.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("encoding entry %s: %w", entries[i].ID, err)
+		}
+	}
+
+	a.pages++
+	page := fmt.Sprintf("/%06d%s", a.pages, archivePageExt)
+
+	err := a.writeFile(archiveBlocksFile+page, blocksData.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return a.writeFile(archiveEntriesFile+page, entriesData.Bytes())
+}
+
+func (a *ArchiveWriter) close() error {
+	manifest, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	err = a.writeFile(archiveManifestFile, manifest)
+	if err != nil {
+		return err
+	}
+
+	if err = a.tw.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+
+	return a.gz.Close()
+}
+
+func (a *ArchiveWriter) writeFile(name string, data []byte) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: a.manifest.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s header: %w", name, err)
+	}
+
+	if _, err = a.tw.Write(data); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// ReadArchive - decode an archive and check it matches its manifest
+// the blocks are not validated, it must be done by the chain
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip: %w", err)
+	}
+	defer gz.Close()
+
+	archive := &Archive{}
+	hasManifest := false
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+
+		// the pages are read in the archive order, version 1 has a single blocks.ndjson and entries.ndjson
+		switch {
+		case header.Name == archiveManifestFile:
+			err = json.NewDecoder(tr).Decode(&archive.Manifest)
+			hasManifest = true
+		case isArchivePage(header.Name, archiveBlocksFile):
+			var blocks []Block
+			blocks, err = ReadBlocks(tr)
+			archive.Blocks = append(archive.Blocks, blocks...)
+		case isArchivePage(header.Name, archiveEntriesFile):
+			var entries []Entry
+			entries, err = readEntries(tr)
+			archive.Entries = append(archive.Entries, entries...)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", header.Name, err)
+		}
+	}
+
+	if !hasManifest {
+		return nil, fmt.Errorf("missing %s", archiveManifestFile)
+	}
+
+	return archive, archive.check()
+}
+
+// isArchivePage - the file is a page of the kind, or its single file on version 1
+func isArchivePage(name, kind string) bool {
+	return name == kind+archivePageExt || (strings.HasPrefix(name, kind+"/") && strings.HasSuffix(name, archivePageExt))
+}
+
+// check - the content must be described by the manifest
+func (a *Archive) check() error {
+	m := a.Manifest
+	if m.FormatVersion < 1 || m.FormatVersion > ARCHIVE_FORMAT_VERSION {
+		return fmt.Errorf("unsupported archive format version %d", m.FormatVersion)
+	}
+
+	if len(a.Blocks) != m.Blocks || len(a.Entries) != m.Entries {
+		return fmt.Errorf("archive has %d blocks and %d entries, manifest describes %d and %d", len(a.Blocks), len(a.Entries), m.Blocks, m.Entries)
+	}
+
+	if len(a.Blocks) == 0 {
+		return nil
+	}
+
+	head := a.Blocks[len(a.Blocks)-1]
+	if head.ID != m.HeadID || head.Hash != m.HeadHash {
+		return fmt.Errorf("archive head %s [%s] differs from manifest %s [%s]", head.ID, head.Hash, m.HeadID, m.HeadHash)
+	}
+
+	return nil
+}
+
+// BlockEntries - entries of each batch block, ordered by index
+func (a *Archive) BlockEntries() map[uuid.UUID][]Entry {
+	byBlock := map[uuid.UUID][]Entry{}
+	for _, entry := range a.Entries {
+		byBlock[entry.BlockID] = append(byBlock[entry.BlockID], entry)
+	}
+
+	for blockID, entries := range byBlock {
+		sorted := make([]Entry, len(entries))
+		for _, entry := range entries {
+			if entry.Index >= 0 && entry.Index < len(sorted) {
+				sorted[entry.Index] = entry
+			}
+		}
+		byBlock[blockID] = sorted
+	}
+
+	return byBlock
+}
+
+func readEntries(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	decoder := json.NewDecoder(r)
+	for {
+		var entry Entry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding entry %d: %w", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+}
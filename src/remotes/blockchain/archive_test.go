@@ -0,0 +1,112 @@
+package blockchain_test
+
+import (
+	"bytes"
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	entries := _mockEntries(3)
+	batchBlock, err := blockchain.NewBatchBlock(entries)
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(batchBlock)
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	stored := make([]blockchain.Entry, len(entries))
+	for i, entry := range entries {
+		stored[i] = *entry
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+	var buf bytes.Buffer
+	err = blockchain.NewArchive(blocks, stored, chain.PublicKeys()).Write(&buf)
+	assert.Nil(t, err)
+
+	archive, err := blockchain.ReadArchive(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.ARCHIVE_FORMAT_VERSION, archive.Manifest.FormatVersion)
+	assert.Equal(t, len(blocks), len(archive.Blocks))
+	assert.Equal(t, blocks[len(blocks)-1].Hash, archive.Manifest.HeadHash)
+	assert.Len(t, archive.Manifest.PublicKeys, 1)
+
+	err = chain.ValidateBlocks(archive.Blocks)
+	assert.Nil(t, err)
+
+	batch := archive.Blocks[1]
+	err = batch.VerifyEntries(archive.BlockEntries()[batch.ID])
+	assert.Nil(t, err)
+
+	// a manifest that does not describe the content is rejected
+	archive.Manifest.Blocks--
+	buf.Reset()
+	err = archive.Write(&buf)
+	assert.Nil(t, err)
+	_, err = blockchain.ReadArchive(&buf)
+	assert.ErrorContains(t, err, "manifest describes")
+
+	archive.Manifest.Blocks++
+	archive.Manifest.FormatVersion = blockchain.ARCHIVE_FORMAT_VERSION + 1
+	buf.Reset()
+	err = archive.Write(&buf)
+	assert.Nil(t, err)
+	_, err = blockchain.ReadArchive(&buf)
+	assert.ErrorContains(t, err, "unsupported archive format version")
+}
+
+func TestArchiveWriterPages(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	entries := _mockEntries(2)
+	batchBlock, err := blockchain.NewBatchBlock(entries)
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(batchBlock)
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	stored := []blockchain.Entry{*entries[0], *entries[1]}
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	var buf bytes.Buffer
+	w := blockchain.NewArchiveWriter(&buf, chain.PublicKeys())
+	assert.Nil(t, w.WritePage(blocks[:2], stored))
+	assert.Nil(t, w.WritePage(blocks[2:4], nil))
+	assert.Nil(t, w.WritePage(blocks[4:], nil))
+	assert.Nil(t, w.Close())
+
+	// the pages are read back in order and described by the manifest written after them
+	archive, err := blockchain.ReadArchive(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(blocks), archive.Manifest.Blocks)
+	assert.Equal(t, 2, archive.Manifest.Entries)
+	assert.Equal(t, uint(0), archive.Manifest.FirstSeqID)
+	assert.Equal(t, blocks[len(blocks)-1].SeqID, archive.Manifest.LastSeqID)
+	assert.Equal(t, blocks[len(blocks)-1].Hash, archive.Manifest.HeadHash)
+	assert.Len(t, archive.Blocks, len(blocks))
+
+	err = chain.ValidateBlocks(archive.Blocks)
+	assert.Nil(t, err)
+	err = archive.Blocks[1].VerifyEntries(archive.BlockEntries()[archive.Blocks[1].ID])
+	assert.Nil(t, err)
+}
@@ -1,7 +1,6 @@
 package blockchain
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -13,12 +12,28 @@ import (
 const TRANSACTION_CODE_SYSTEM_ID = "system_id"
 const GENESIS_HASH_BLOCK = "01f4913e4f39713b5d2260b443ff30c2b696256d3488731a8b4587ab3fb6983f"
 const GENESIS_ID_BLOCK = "6ec9d09f-fee4-494c-9309-f603f275f4df"
+const GENESIS_SYSTEM_ID = "genesis"
+
+// Hash format versions, define how the block payload is serialized and hashed
+const (
+	// HASH_VERSION_LEGACY - transaction serialized by encoding/json, kept to verify old blocks and the genesis
+	HASH_VERSION_LEGACY uint = 0
+	// HASH_VERSION_CANONICAL - transaction serialized as RFC 8785 canonical json, the version is hashed too
+	HASH_VERSION_CANONICAL uint = 1
+	// HASH_VERSION_PREIMAGE - canonical transaction and a length prefixed preimage
+	// also covering the system, the tags, the signing date and the digest algorithm
+	HASH_VERSION_PREIMAGE uint = 2
+)
+
+// CURRENT_HASH_VERSION - version used to hash the new blocks
+const CURRENT_HASH_VERSION = HASH_VERSION_PREIMAGE
 
 type Block struct {
 	ID uuid.UUID `gorm:"primarykey"`
 
 	// Used to link this block to the previous
-	LastBlockID uuid.UUID
+	// unique on the chain, two blocks chained to the same block are a fork
+	LastBlockID uuid.UUID `gorm:"uniqueIndex:idx_blocks_chain_last_block,priority:2"`
 
 	// Used to link this block to the previous and check the consistency
 	LastBlockHash string
@@ -29,7 +44,15 @@ type Block struct {
 	Transaction map[string]interface{} `gorm:"-"`
 
 	// Metadata used to filter blocks by a system in database
-	SystemID string
+	SystemID string `gorm:"uniqueIndex:idx_blocks_system_idempotency_key,priority:1"`
+
+	// Unverifyed metadata
+	// Key sent by the client to not store the same log twice, unique by system when setted
+	IdempotencyKey string `gorm:"not null;default:'';uniqueIndex:idx_blocks_system_idempotency_key,priority:2,where:idempotency_key <> ''"`
+
+	// Sub-chain of the block, the system id of its logs, empty on the global chain
+	// sub-chains start linked to the genesis and have their own seqID sequence
+	ChainID string `gorm:"index;not null;default:'';uniqueIndex:idx_blocks_chain_seq,priority:1;uniqueIndex:idx_blocks_chain_last_block,priority:1"`
 
 	// Unverifyed metadata
 	// Use only to mark a block in a chain
@@ -37,18 +60,42 @@ type Block struct {
 
 	// Squential id to incremented when added in a chain
 	// Used to verify the block position in a chain
-	SeqID uint `gorm:"index"`
+	// unique on the chain
+	SeqID uint `gorm:"index;uniqueIndex:idx_blocks_chain_seq,priority:2"`
 
 	// Hash value from the payload and others metadata
 	// Used to verify if the block its consistent
 	Hash string
+	// Format used to serialize and hash the block, 0 on blocks hashed before it was recorded
+	HashVersion uint `gorm:"not null;default:0"`
+	// Digest algorithm of the hash, empty on blocks hashed before it was recorded (sha256)
+	HashAlgorithm string
 
 	// Used to sign a block and keeping then trustable
 	Signature string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	SignedAt  time.Time
-	HashedAt  string
+	// Scheme used to sign the block, empty on blocks signed before it was recorded (pgp)
+	SignatureScheme string
+	// Fingerprint of the key that signed the block, empty on blocks signed before it was recorded
+	KeyFingerprint string `gorm:"index"`
+	// Digest of the signed metadata envelope, empty when the metadata is not signed
+	MetadataHash string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	SignedAt     time.Time
+	HashedAt     string
+}
+
+// signingTime - date the block was signed, the creation date on blocks signed before it was recorded
+func (b *Block) signingTime() time.Time {
+	if !b.SignedAt.IsZero() {
+		return b.SignedAt
+	}
+	return b.CreatedAt
+}
+
+// IsReservedSystemID - system ids used by blocks created by the chain itself
+func IsReservedSystemID(systemID string) bool {
+	return systemID == GENESIS_SYSTEM_ID || systemID == KEY_ROTATION_SYSTEM_ID || systemID == BATCH_SYSTEM_ID || systemID == ANCHOR_SYSTEM_ID
 }
 
 func NewBlock(systemID string, transaction map[string]interface{}, tags ...string) *Block {
@@ -60,20 +107,29 @@ func NewBlock(systemID string, transaction map[string]interface{}, tags ...strin
 	}
 }
 
-// Hashable - returns the string to be hashed
+// Hashable - returns the string to be hashed by the legacy and canonical versions
 // Uses the variables initialized on HashBlock()
+// the fields are joined by dots, so it is ambiguous, use Preimage()
 func (b *Block) Hashable() string {
+	if b.HashVersion != HASH_VERSION_LEGACY {
+		return fmt.Sprintf("v%d.%d.%s.%s.%s.%s.%s", b.HashVersion, b.SeqID, b.ID.String(), b.LastBlockID.String(), b.LastBlockHash, b.TransactionStr, b.HashedAt)
+	}
 	return fmt.Sprintf("%d.%s.%s.%s.%s.%s", b.SeqID, b.ID.String(), b.LastBlockID.String(), b.LastBlockHash, b.TransactionStr, b.HashedAt)
 }
 
 // Signable - returns the values to be signed by chain
 // Important the block be Hashed first
+// blocks with metadata envelope also sign the envelope digest
 func (b *Block) Signable() string {
+	if b.HasMetadataEnvelope() {
+		return fmt.Sprintf("%s.%s.%s", b.ID.String(), b.Hash, b.MetadataHash)
+	}
 	return fmt.Sprintf("%s.%s", b.ID.String(), b.Hash)
 }
 
 // HashBlock - calc the hash for block and add metadata inside them
 // After be hashed the block can be signed and will be valid
+// the transaction is serialized following the block HashVersion
 func (b *Block) HashBlock() error {
 
 	if b.ID == uuid.Nil {
@@ -85,7 +141,7 @@ func (b *Block) HashBlock() error {
 
 	b.Transaction[TRANSACTION_CODE_SYSTEM_ID] = b.SystemID
 
-	t, err := json.Marshal(&b.Transaction)
+	t, err := b.serializeTransaction()
 	if err != nil {
 		return fmt.Errorf("marshaling transaction: %w", err)
 	}
@@ -102,12 +158,44 @@ func (b *Block) HashBlock() error {
 	return nil
 }
 
-// CalcHash - calc the hash to the block
+func (b *Block) serializeTransaction() ([]byte, error) {
+	switch b.HashVersion {
+	case HASH_VERSION_LEGACY:
+		return json.Marshal(&b.Transaction)
+	case HASH_VERSION_CANONICAL, HASH_VERSION_PREIMAGE:
+		return CanonicalJSON(&b.Transaction)
+	}
+	return nil, fmt.Errorf("unknown hash version %d", b.HashVersion)
+}
+
+// CheckHashVersion - check the payload was serialized as its hash version requires
+// legacy blocks are accepted as they are, their serialization depends on the go encoder
+func (b *Block) CheckHashVersion() error {
+	switch b.HashVersion {
+	case HASH_VERSION_LEGACY:
+		return nil
+	case HASH_VERSION_CANONICAL, HASH_VERSION_PREIMAGE:
+		if !isCanonical(b.TransactionStr) {
+			return fmt.Errorf("transaction is not canonical json")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown hash version %d", b.HashVersion)
+}
+
+// CalcHash - calc the hash to the block with its digest algorithm
+// empty when the algorithm is not supported
 func (b *Block) CalcHash() string {
-	hashable := b.Hashable()
+	hash, err := Digest(blockHashAlgorithm(b), b.Preimage())
+	if err != nil {
+		return ""
+	}
+	return hash
+}
 
-	hash := sha256.Sum256([]byte(hashable))
-	return fmt.Sprintf("%x", hash[:])
+// IsGenesis - check if the block is the well known genesis of its hash algorithm
+func (b *Block) IsGenesis() bool {
+	return b.ID.String() == GENESIS_ID_BLOCK && b.Hash != "" && b.Hash == GenesisHash(b.HashAlgorithm)
 }
 
 // Unpack - unpack the payload stored on transactionString to transaction struct
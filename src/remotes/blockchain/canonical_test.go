@@ -0,0 +1,80 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	cases := map[string]string{
+		// RFC 8785 appendix B numbers
+		`[0, -0, 1e21, 1e-7, 9007199254740991, 333333333.3333333, 1e23, 0.000001, 4.35, 0.002, 1e+22]`: `[0,0,1e+21,1e-7,9007199254740991,333333333.3333333,1e+23,0.000001,4.35,0.002,1e+22]`,
+		// keys sorted by utf-16 code units, html is not escaped
+		`{"b": "<a&b>", "a": {"z": true, "y": null}, "ﬁ": 1, "😀": 2}`: `{"a":{"y":null,"z":true},"b":"<a&b>","😀":2,"ﬁ":1}`,
+		`{"s": "line\nbreak\u0001\"quoted\"\\"}`:                      `{"s":"line\nbreak\u0001\"quoted\"\\"}`,
+	}
+
+	for in, expected := range cases {
+		out, err := blockchain.Canonicalize([]byte(in))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(out))
+	}
+
+	out, err := blockchain.CanonicalJSON(map[string]interface{}{"table": "user", "amount": 10.50, "id": 3})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"amount":10.5,"id":3,"table":"user"}`, string(out))
+
+	_, err = blockchain.Canonicalize([]byte(`{"a":1} {"b":2}`))
+	assert.Error(t, err)
+}
+
+func TestChainHashVersion(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.HASH_VERSION_LEGACY, chain.GenesisBlock.HashVersion)
+
+	block := _mockBlock()
+	block.Transaction["html"] = "<b>&</b>"
+	addedBlock, err := chain.AppendBlock(block)
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.CURRENT_HASH_VERSION, addedBlock.HashVersion)
+	assert.Contains(t, addedBlock.TransactionStr, "<b>&</b>")
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	resign := func(b *blockchain.Block) {
+		b.Hash = b.CalcHash()
+		b.Signature, err = signer.Sign([]byte(b.Signable()))
+		assert.Nil(t, err)
+	}
+
+	// legacy blocks keep the go serialization, with html escaping
+	legacy := chain.Chain[1]
+	legacy.HashVersion = blockchain.HASH_VERSION_LEGACY
+	legacy.TransactionStr = `{"html":"\u003cb\u003e\u0026\u003c/b\u003e","system_id":"sauron"}`
+	resign(&legacy)
+	chain.Chain[1] = legacy
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	// the same payload is not accepted on canonical blocks
+	legacy.HashVersion = blockchain.HASH_VERSION_CANONICAL
+	resign(&legacy)
+	chain.Chain[1] = legacy
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "transaction is not canonical json")
+
+	legacy.HashVersion = blockchain.CURRENT_HASH_VERSION + 1
+	resign(&legacy)
+	chain.Chain[1] = legacy
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "unknown hash version")
+}
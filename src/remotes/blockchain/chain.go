@@ -2,95 +2,254 @@ package blockchain
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/google/uuid"
 )
 
-var chain *BlockChain
-
+// BlockChain - signs and verifies the blocks, safe to be shared by concurrent appends and validations
+// Chain and GenesisBlock keep an in memory chain, used only by AppendBlock and Validate
 type BlockChain struct {
 	GenesisBlock Block
 
-	Chain      []Block `gorm:"-"`
-	privKey    string  `json:"-" gorm:"-"`
-	passphrase string  `json:"-" gorm:"-"`
-	PubKey     string
+	Chain []Block  `gorm:"-"`
+	keys  *keyRing `json:"-" gorm:"-"`
+	// chainMu guards the in memory chain
+	chainMu sync.Mutex
+
+	// mu guards the signer and the settings
+	mu            sync.RWMutex
+	signer        Signer `json:"-" gorm:"-"`
+	hashAlgorithm string
+	signMetadata  bool
+	// workers verifying the block signatures and hashes concurrently on the validation
+	workers int
+	PubKey  string
 }
 
-// InitChain start a chain with ou whithout a block
-func InitChain(pubKey string, blocks ...Block) {
-	chain = &BlockChain{
-		PubKey: pubKey,
-		Chain:  blocks,
+// NewBlockChain - start a chain with ou whithout a block
+// the pubKey is an armored PGP key, other schemes are added using AddVerifier
+func NewBlockChain(pubKey string, blocks ...Block) *BlockChain {
+	chain := &BlockChain{
+		PubKey:  pubKey,
+		Chain:   blocks,
+		keys:    newKeyRing(),
+		workers: 1,
+	}
+
+	if pubKey != "" {
+		chain.AddVerifier(NewPGPVerifier(pubKey))
 	}
 
 	if len(blocks) > 0 {
 		chain.GenesisBlock = blocks[0]
 	}
-}
 
-// Get - return a instance of a initialized chain
-func Get() *BlockChain {
-	if chain == nil {
-		panic("chain not initialized")
-	}
 	return chain
 }
 
-// SetAuth - used to allow a blockchain instance sign new blocks
+// SetAuth - used to allow a blockchain instance sign new blocks using a PGP key
 func (b *BlockChain) SetAuth(privKey, passphrase string) {
-	b.passphrase = passphrase
-	b.privKey = privKey
+	if privKey == "" || passphrase == "" {
+		b.SetSigner(nil)
+		return
+	}
+	b.SetSigner(NewPGPSigner(privKey, passphrase))
+}
+
+// SetSigner - used to allow a blockchain instance sign new blocks with any scheme
+func (b *BlockChain) SetSigner(signer Signer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.signer = signer
+}
+
+// getSigner - the signer of the new blocks, nil when the chain can not sign
+func (b *BlockChain) getSigner() Signer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.signer
+}
+
+// AddVerifier - trust a key to check the blocks signed by it
+// the first key added of each scheme also checks the blocks signed before fingerprints were recorded
+func (b *BlockChain) AddVerifier(verifier Verifier) {
+	b.keys.add(verifier)
+}
+
+// SetHashAlgorithm - digest algorithm used to hash the new blocks, the stored ones keep their own
+func (b *BlockChain) SetHashAlgorithm(algorithm string) error {
+	err := CheckHashAlgorithm(algorithm)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hashAlgorithm = algorithm
+	return nil
+}
+
+// HashAlgorithm - digest algorithm used to hash the new blocks
+func (b *BlockChain) HashAlgorithm() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.hashAlgorithm == "" {
+		return DEFAULT_HASH_ALGORITHM
+	}
+	return b.hashAlgorithm
+}
+
+// SetSignMetadata - sign the metadata envelope (system, tags, creation date and signer) of the new blocks
+func (b *BlockChain) SetSignMetadata(sign bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.signMetadata = sign
+}
+
+// SetValidationWorkers - number of blocks verified concurrently on the validation, the links are still checked in order
+func (b *BlockChain) SetValidationWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.workers = workers
+}
+
+func (b *BlockChain) validationWorkers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.workers
 }
 
 // HaveAuth - to verify if the auth is setted
 func (b *BlockChain) HaveAuth() bool {
-	return b.privKey != "" && b.passphrase != ""
+	return b.getSigner() != nil
 }
 
 // Checkacble - to verify if a blokchain can verify the signature from the blocks
 func (b *BlockChain) Checkable() bool {
-	return b.PubKey != ""
+	return b.keys.size() > 0
 }
 
-// unlockPrivKey - retrieve the privkey given a password
-func (b *BlockChain) unlockPrivKey() (*crypto.KeyRing, error) {
-	passphrase := []byte(b.passphrase) // Private key passphrase
+// PublicKeys - the keys trusted by the chain, configured and learned from rotations
+func (b *BlockChain) PublicKeys() []Verifier {
+	return b.keys.list()
+}
 
-	privateKeyObj, err := crypto.NewKeyFromArmored(b.privKey)
+// ActiveKey - fingerprint of the key that signs the next blocks
+func (b *BlockChain) ActiveKey() string {
+	signer := b.getSigner()
+	if signer == nil {
+		return ""
+	}
+	return signer.Fingerprint()
+}
+
+// Rotated - check if the key was announced by a rotation block
+func (b *BlockChain) Rotated(fingerprint string) bool {
+	b.keys.mu.RLock()
+	defer b.keys.mu.RUnlock()
+
+	for _, r := range b.keys.rotations {
+		if r.fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// RotateKey - append a block signed by the current key announcing the next one
+// and start signing the new blocks with the next key
+func (b *BlockChain) RotateKey(next Signer) (*Block, error) {
+	verifier, err := next.Verifier()
 	if err != nil {
-		return nil, fmt.Errorf("reading privKey: %w", err)
+		return nil, fmt.Errorf("getting next key verifier: %w", err)
 	}
-	unlockedKey, err := privateKeyObj.Unlock(passphrase)
+
+	rotationBlock, err := b.AppendBlock(NewKeyRotationBlock(verifier))
 	if err != nil {
-		return nil, fmt.Errorf("opening privkey with passphrase: %w", err)
+		return nil, fmt.Errorf("appending rotation block: %w", err)
 	}
 
-	signingKeyRing, err := crypto.NewKeyRing(unlockedKey)
+	err = b.ActivateRotation(rotationBlock, next)
 	if err != nil {
-		return nil, fmt.Errorf("creating keyring")
+		return nil, fmt.Errorf("activating next key: %w", err)
 	}
-	return signingKeyRing, nil
+
+	return rotationBlock, nil
 }
 
-// getPubKey - return a instance of pubkey from the pubkey string
-func (b *BlockChain) getPubKey() (*crypto.KeyRing, error) {
-	publicKeyObj, err := crypto.NewKeyFromArmored(b.PubKey)
+// ActivateRotation - used after a rotation block was chained and stored
+// trust the announced key and use the next signer to sign the new blocks
+func (b *BlockChain) ActivateRotation(rotationBlock *Block, next Signer) error {
+	fingerprint, err := b.learnRotation(rotationBlock)
 	if err != nil {
-		return nil, fmt.Errorf("reading pubKey: %w", err)
+		return err
 	}
-	signingKeyRing, err := crypto.NewKeyRing(publicKeyObj)
+
+	if next.Fingerprint() != fingerprint {
+		return fmt.Errorf("next key %s is not the announced key %s", next.Fingerprint(), fingerprint)
+	}
+
+	b.SetSigner(next)
+	return nil
+}
+
+// LearnRotations - trust the keys announced by stored rotation blocks, ordered by seqID
+// each rotation must be signed by the key active before it
+func (b *BlockChain) LearnRotations(rotationBlocks []Block) error {
+	for i := range rotationBlocks {
+		block := rotationBlocks[i]
+
+		err := b.validateBlock(&block)
+		if err != nil {
+			return fmt.Errorf("validating rotation block %d: %w", block.SeqID, err)
+		}
+
+		active := b.keys.activeAt(block.SeqID)
+		if active != "" && block.KeyFingerprint != active {
+			return fmt.Errorf("rotation block %d signed by %s, active key is %s", block.SeqID, block.KeyFingerprint, active)
+		}
+
+		_, err = b.learnRotation(&block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// learnRotation - trust the key announced by the rotation block from its seqID
+func (b *BlockChain) learnRotation(block *Block) (string, error) {
+	rotation, err := block.KeyRotation()
 	if err != nil {
-		return nil, fmt.Errorf("generating keyring: %w", err)
+		return "", fmt.Errorf("reading rotation block: %w", err)
 	}
-	return signingKeyRing, nil
+
+	verifier, err := NewVerifier(rotation.Scheme, rotation.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("loading announced key: %w", err)
+	}
+
+	if verifier.Fingerprint() != rotation.Fingerprint {
+		return "", fmt.Errorf("announced fingerprint %s does not match key %s", rotation.Fingerprint, verifier.Fingerprint())
+	}
+
+	b.keys.add(verifier)
+	b.keys.addRotation(block.SeqID, rotation.Fingerprint, block.signingTime())
+
+	return rotation.Fingerprint, nil
 }
 
-// AppendBlock - verify add and sign new block on the chain
+// AppendBlock - verify add and sign new block on the in memory chain
 // the chain needs to be initialized first, needs to have at last 1 block
 func (b *BlockChain) AppendBlock(block *Block) (*Block, error) {
+	b.chainMu.Lock()
+	defer b.chainMu.Unlock()
+
 	size := len(b.Chain)
 	if size == 0 {
 		return nil, fmt.Errorf("empty chain")
@@ -116,17 +275,7 @@ func (b *BlockChain) ChainBlocks(lastBlock, block *Block) (*Block, error) {
 		return nil, fmt.Errorf("not initialized")
 	}
 
-	pubKey, err := b.getPubKey()
-	if err != nil {
-		return nil, fmt.Errorf("getting pubkey: %w", err)
-	}
-
-	privKey, err := b.unlockPrivKey()
-	if err != nil {
-		return nil, fmt.Errorf("unlocking privKey: %w", err)
-	}
-
-	err = b.validateBlock(pubKey, lastBlock)
+	err := b.validateBlock(lastBlock)
 	if err != nil {
 		return nil, fmt.Errorf("validating last block: %w", err)
 	}
@@ -134,13 +283,17 @@ func (b *BlockChain) ChainBlocks(lastBlock, block *Block) (*Block, error) {
 	block.LastBlockHash = lastBlock.Hash
 	block.LastBlockID = lastBlock.ID
 	block.SeqID = lastBlock.SeqID + 1
+	block.HashVersion = CURRENT_HASH_VERSION
+	block.HashAlgorithm = b.HashAlgorithm()
+	// the signing date is covered by the hash, so it is set before hashing
+	block.SignedAt = time.Now().UTC().Truncate(SignedAtPrecision)
 
 	err = block.HashBlock()
 	if err != nil {
 		return nil, fmt.Errorf("hashing block: %w", err)
 	}
 
-	err = b.signBlock(privKey, block)
+	err = b.signBlock(block)
 	if err != nil {
 		return nil, fmt.Errorf("signing block: %w", err)
 	}
@@ -155,11 +308,6 @@ func (b *BlockChain) GenerateGenesis() error {
 		return fmt.Errorf("not initialized")
 	}
 
-	privKey, err := b.unlockPrivKey()
-	if err != nil {
-		return fmt.Errorf("unlocking privKey: %w", err)
-	}
-
 	genesisID, _ := uuid.Parse(GENESIS_ID_BLOCK)
 	genesisBlock := &Block{
 		ID:            genesisID,
@@ -167,96 +315,128 @@ func (b *BlockChain) GenerateGenesis() error {
 		LastBlockHash: "",
 		Transaction:   map[string]interface{}{"there was light": "and become light"},
 		SeqID:         0,
-		SystemID:      "genesis",
+		SystemID:      GENESIS_SYSTEM_ID,
+		// the genesis keeps the legacy format, its hash is well known
+		HashVersion:   HASH_VERSION_LEGACY,
+		HashAlgorithm: b.HashAlgorithm(),
 	}
-	err = genesisBlock.HashBlock()
+	err := genesisBlock.HashBlock()
 	if err != nil {
 		return fmt.Errorf("hashing genesis block: %w", err)
 	}
 
-	err = b.signBlock(privKey, genesisBlock)
+	err = b.signBlock(genesisBlock)
 	if err != nil {
 		return fmt.Errorf("validating genesis block: %w", err)
 	}
 
+	b.chainMu.Lock()
+	defer b.chainMu.Unlock()
 	b.GenesisBlock = *genesisBlock
 	b.Chain = append(b.Chain, *genesisBlock)
 
 	return nil
 }
 
-// Validate the entire chain
+// Validate the entire in memory chain
 func (b *BlockChain) Validate() error {
 
 	if !b.Checkable() {
 		return fmt.Errorf("not initiliazed")
 	}
 
-	pubKey, err := b.getPubKey()
-	if err != nil {
-		return fmt.Errorf("getting PubKey: %w", err)
-	}
+	b.chainMu.Lock()
+	genesis, blocks := b.GenesisBlock, b.Chain
+	b.chainMu.Unlock()
 
-	lastBlock := b.GenesisBlock
+	return b.NewValidatorFrom(genesis).Validate(blocks)
+}
 
-	size := len(b.Chain)
+// ValidateBlocks - validate blocks ordered by seqID, starting on the genesis or on any other block
+// when it does not start on genesis the first block is only checked by itself
+// the blocks are validated on their own view, the in memory chain is not changed
+func (b *BlockChain) ValidateBlocks(blocks []Block) error {
+	return b.NewValidator().Validate(blocks)
+}
 
-	for i := 0; i < size; i++ {
-		block := b.Chain[i]
+func (b *BlockChain) signBlock(block *Block) error {
+	// read once, a rotation can change the signer while the block is signed
+	b.mu.RLock()
+	signer, signMetadata := b.signer, b.signMetadata
+	b.mu.RUnlock()
 
-		err = b.validateBlock(pubKey, &block)
-		if err != nil {
-			return fmt.Errorf("validating block: %w", err)
-		}
+	if signer == nil {
+		return fmt.Errorf("not initialized")
+	}
 
-		if block.ID.String() == GENESIS_ID_BLOCK && block.Hash == GENESIS_HASH_BLOCK {
-			continue
+	block.SignatureScheme = signer.Scheme()
+	block.KeyFingerprint = signer.Fingerprint()
+	block.MetadataHash = ""
+
+	if signMetadata {
+		// the creation date is covered by the envelope, so it is not left to the database
+		if block.CreatedAt.IsZero() {
+			block.CreatedAt = time.Now().UTC().Truncate(SignedAtPrecision)
 		}
 
-		if block.LastBlockHash != lastBlock.Hash {
-			return fmt.Errorf("chain is broken, last block is different! blockID: %s seqBlock: %d Comparation: [%s] [%s] LastBLockId: [%s]",
-				block.ID.String(),
-				block.SeqID,
-				block.LastBlockHash,
-				lastBlock.Hash,
-				lastBlock.ID.String())
+		metadataHash, err := block.CalcMetadataHash()
+		if err != nil {
+			return fmt.Errorf("hashing metadata: %w", err)
 		}
+		block.MetadataHash = metadataHash
+	}
 
-		lastBlock = block
+	signature, err := signer.Sign([]byte(block.Signable()))
+	if err != nil {
+		return fmt.Errorf("generate signature: %w", err)
 	}
 
+	if block.SignedAt.IsZero() {
+		block.SignedAt = time.Now()
+	}
+	block.Signature = signature
+
 	return nil
 }
 
-func (b *BlockChain) Clean() {
-	b.Chain = nil
-	b.GenesisBlock = Block{}
+func (b *BlockChain) validateBlock(block *Block) error {
+	return verifyBlock(b.keys, block)
+}
+
+// VerifyBlock - check the block signature and hash by itself, its links to the chain are not checked
+func (b *BlockChain) VerifyBlock(block *Block) error {
+	return b.validateBlock(block)
 }
 
-func (b *BlockChain) signBlock(privKey *crypto.KeyRing, block *Block) error {
+// ValidateSignature - check only the block signature, with the key that signed it
+func (b *BlockChain) ValidateSignature(block *Block) error {
+	return verifySignature(b.keys, block)
+}
 
-	message := crypto.NewPlainMessage([]byte(block.Signable()))
-	pgpSignature, err := privKey.SignDetached(message)
+func verifySignature(keys *keyRing, block *Block) error {
+	verifier, err := keys.verifierFor(block)
 	if err != nil {
-		return fmt.Errorf("generate signature: %w", err)
+		return fmt.Errorf("getting verifier: %w", err)
 	}
 
-	block.SignedAt = time.Now()
-	block.Signature = formatSignatureToPGP(pgpSignature.Data)
-
-	return nil
+	return verifier.Verify([]byte(block.Signable()), block.Signature)
 }
 
-func (b *BlockChain) validateBlock(pubKey *crypto.KeyRing, block *Block) error {
+// verifyBlock - check the block signature with the key that signed it and the block hash
+func verifyBlock(keys *keyRing, block *Block) error {
 
-	message := crypto.NewPlainMessage([]byte(block.Signable()))
+	err := CheckHashAlgorithm(blockHashAlgorithm(block))
+	if err != nil {
+		return fmt.Errorf("checking hash algorithm: %w", err)
+	}
 
-	pgpSignature, err := crypto.NewPGPSignatureFromArmored(block.Signature)
+	// checked first to report the tampering, a forged envelope digest fails on the signature
+	err = block.CheckMetadata()
 	if err != nil {
-		return fmt.Errorf("opening signature: %w", err)
+		return err
 	}
 
-	err = pubKey.VerifyDetached(message, pgpSignature, crypto.GetUnixTime())
+	err = verifySignature(keys, block)
 	if err != nil {
 		return fmt.Errorf("checking signature: %w", err)
 	}
@@ -266,5 +446,10 @@ func (b *BlockChain) validateBlock(pubKey *crypto.KeyRing, block *Block) error {
 		return fmt.Errorf("invalid hash block: %v != %v", block.Hash, hash)
 	}
 
+	err = block.CheckHashVersion()
+	if err != nil {
+		return fmt.Errorf("checking hash version: %w", err)
+	}
+
 	return nil
 }
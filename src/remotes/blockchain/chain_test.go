@@ -1,9 +1,14 @@
 package blockchain_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"logger/remotes/blockchain"
+	"sync"
 	"testing"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
@@ -96,9 +101,7 @@ func TestChain(t *testing.T) {
 	privKey, pubKey, err := _generateMockKey(pass)
 	assert.Nil(t, err)
 
-	blockchain.InitChain(pubKey)
-
-	chain := blockchain.Get()
+	chain := blockchain.NewBlockChain(pubKey)
 
 	chain.SetAuth(privKey, pass)
 
@@ -143,9 +146,7 @@ func TestChainAppendBlock(t *testing.T) {
 	privKey, pubKey, err := _generateMockKey(pass)
 	assert.Nil(t, err)
 
-	blockchain.InitChain(pubKey)
-
-	chain := blockchain.Get()
+	chain := blockchain.NewBlockChain(pubKey)
 
 	chain.SetAuth(privKey, pass)
 
@@ -169,9 +170,7 @@ func TestChainBlockFraudSignature(t *testing.T) {
 	privKey, pubKey, err := _generateMockKey(pass)
 	assert.Nil(t, err)
 
-	blockchain.InitChain(pubKey)
-
-	chain := blockchain.Get()
+	chain := blockchain.NewBlockChain(pubKey)
 
 	chain.SetAuth(privKey, pass)
 
@@ -201,9 +200,7 @@ func TestChainBlockFraudHash(t *testing.T) {
 	privKey, pubKey, err := _generateMockKey(pass)
 	assert.Nil(t, err)
 
-	blockchain.InitChain(pubKey)
-
-	chain := blockchain.Get()
+	chain := blockchain.NewBlockChain(pubKey)
 
 	chain.SetAuth(privKey, pass)
 
@@ -229,3 +226,314 @@ func TestChainBlockFraudHash(t *testing.T) {
 	err = chain.Validate()
 	assert.ErrorContains(t, err, "invalid hash block")
 }
+
+func _generateMockEd25519() (blockchain.Signer, blockchain.Verifier) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	return blockchain.NewEd25519Signer(priv), blockchain.NewEd25519Verifier(pub)
+}
+
+func _generateMockECDSA() (blockchain.Signer, blockchain.Verifier) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return blockchain.NewECDSASigner(priv), blockchain.NewECDSAVerifier(&priv.PublicKey)
+}
+
+func TestChainSigners(t *testing.T) {
+	ed25519Signer, ed25519Verifier := _generateMockEd25519()
+	ecdsaSigner, ecdsaVerifier := _generateMockECDSA()
+
+	cases := map[string]struct {
+		signer   blockchain.Signer
+		verifier blockchain.Verifier
+	}{
+		blockchain.SchemeEd25519:   {ed25519Signer, ed25519Verifier},
+		blockchain.SchemeECDSAP256: {ecdsaSigner, ecdsaVerifier},
+	}
+
+	for scheme, c := range cases {
+		t.Run(scheme, func(t *testing.T) {
+			chain := blockchain.NewBlockChain("")
+			chain.SetSigner(c.signer)
+			chain.AddVerifier(c.verifier)
+
+			err := chain.GenerateGenesis()
+			assert.Nil(t, err)
+
+			addedBlock, err := chain.AppendBlock(_mockBlock())
+			assert.Nil(t, err)
+			assert.Equal(t, scheme, addedBlock.SignatureScheme)
+
+			err = chain.Validate()
+			assert.Nil(t, err)
+
+			block1 := chain.Chain[1]
+			block1.Transaction["table"] = "admin"
+			err = block1.HashBlock()
+			assert.Nil(t, err)
+			chain.Chain[1] = block1
+
+			err = chain.Validate()
+			assert.ErrorContains(t, err, "signature verification failed")
+		})
+	}
+}
+
+func TestChainMixedSchemes(t *testing.T) {
+	pass := "very very long long key"
+	privKey, pubKey, err := _generateMockKey(pass)
+	assert.Nil(t, err)
+
+	chain := blockchain.NewBlockChain(pubKey)
+	chain.SetAuth(privKey, pass)
+
+	err = chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	pgpBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.SchemePGP, pgpBlock.SignatureScheme)
+
+	signer, verifier := _generateMockEd25519()
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	edBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.SchemeEd25519, edBlock.SignatureScheme)
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	// legacy blocks without scheme are verified as pgp
+	chain.Chain[1].SignatureScheme = ""
+	err = chain.Validate()
+	assert.Nil(t, err)
+}
+
+func TestChainKeyRotation(t *testing.T) {
+	pass := "very very long long key"
+	privKey, pubKey, err := _generateMockKey(pass)
+	assert.Nil(t, err)
+
+	chain := blockchain.NewBlockChain(pubKey)
+	chain.SetAuth(privKey, pass)
+	rootKey := chain.ActiveKey()
+
+	err = chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	next, _ := _generateMockEd25519()
+	rotationBlock, err := chain.RotateKey(next)
+	assert.Nil(t, err)
+	assert.True(t, rotationBlock.IsKeyRotation())
+	assert.Equal(t, rootKey, rotationBlock.KeyFingerprint, "rotation is signed by the outgoing key")
+	assert.Equal(t, next.Fingerprint(), chain.ActiveKey())
+
+	addedBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.Equal(t, next.Fingerprint(), addedBlock.KeyFingerprint)
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	// an auditor knowing only the root key learns the next one from the chain
+	blocks := chain.Chain
+	auditor := blockchain.NewBlockChain(pubKey, blocks...)
+	err = auditor.Validate()
+	assert.Nil(t, err)
+	assert.True(t, auditor.Rotated(next.Fingerprint()))
+
+	// segments after the rotation are checked against the announced key
+	segment := blockchain.NewBlockChain(pubKey)
+	err = segment.LearnRotations([]blockchain.Block{*rotationBlock})
+	assert.Nil(t, err)
+	segment.GenesisBlock = blocks[2]
+	segment.Chain = blocks[3:]
+	err = segment.Validate()
+	assert.Nil(t, err)
+}
+
+func TestChainKeyRotationRetiredKey(t *testing.T) {
+	pass := "very very long long key"
+	privKey, pubKey, err := _generateMockKey(pass)
+	assert.Nil(t, err)
+
+	chain := blockchain.NewBlockChain(pubKey)
+	chain.SetAuth(privKey, pass)
+
+	err = chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	next, _ := _generateMockEd25519()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	// the outgoing key keeps signing after the rotation
+	chain.SetAuth(privKey, pass)
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "block not signed by the active key")
+}
+
+func TestChainKeyRotationEarlyKey(t *testing.T) {
+	pass := "very very long long key"
+	privKey, pubKey, err := _generateMockKey(pass)
+	assert.Nil(t, err)
+
+	chain := blockchain.NewBlockChain(pubKey)
+	chain.SetAuth(privKey, pass)
+
+	err = chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	// the next key signs before the rotation announcing it
+	next, verifier := _generateMockEd25519()
+	chain.SetSigner(next)
+	chain.AddVerifier(verifier)
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	chain.SetAuth(privKey, pass)
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "block signed by a key before its rotation")
+}
+
+func TestChainHashAlgorithms(t *testing.T) {
+	algorithms := []string{blockchain.HashSHA256, blockchain.HashSHA512, blockchain.HashSHA3_256, blockchain.HashBLAKE2b256}
+	for _, algorithm := range algorithms {
+		signer, verifier := _generateMockEd25519()
+		chain := blockchain.NewBlockChain("")
+		chain.SetSigner(signer)
+		chain.AddVerifier(verifier)
+
+		err := chain.SetHashAlgorithm(algorithm)
+		assert.Nil(t, err)
+
+		err = chain.GenerateGenesis()
+		assert.Nil(t, err)
+		assert.True(t, chain.GenesisBlock.IsGenesis(), algorithm)
+		assert.Equal(t, blockchain.GenesisHash(algorithm), chain.GenesisBlock.Hash)
+
+		addedBlock, err := chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+		assert.Equal(t, algorithm, addedBlock.HashAlgorithm)
+
+		err = chain.Validate()
+		assert.Nil(t, err, algorithm)
+	}
+
+	err := blockchain.NewBlockChain("").SetHashAlgorithm("md5")
+	assert.ErrorContains(t, err, "unsupported hash algorithm")
+}
+
+func TestChainMixedHashAlgorithms(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	// blocks hashed before the algorithm was recorded are sha256
+	chain.Chain[0].HashAlgorithm = ""
+	chain.Chain[1].HashAlgorithm = ""
+
+	err = chain.SetHashAlgorithm(blockchain.HashSHA512)
+	assert.Nil(t, err)
+	addedBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.Len(t, addedBlock.Hash, 128)
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	chain.Chain[2].HashAlgorithm = blockchain.HashSHA256
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "invalid hash block")
+
+	chain.Chain[2].HashAlgorithm = "md5"
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "unsupported hash algorithm")
+}
+
+func TestChainConcurrentAppendAndValidate(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+	chain.SetSignMetadata(true)
+	chain.SetValidationWorkers(2)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+	snapshot := append([]blockchain.Block{}, chain.Chain...)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := chain.AppendBlock(_mockBlock())
+			assert.Nil(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			// each validation has its own view, the appends do not change it
+			assert.Nil(t, chain.ValidateBlocks(snapshot))
+			assert.Nil(t, chain.ValidateBlocks(snapshot[2:]))
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		chain.SetSigner(signer)
+		chain.SetSignMetadata(true)
+	}()
+	wg.Wait()
+
+	assert.Len(t, chain.Chain, 10)
+	assert.Nil(t, chain.Validate())
+}
+
+func TestChainVerifyBlock(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	block, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	stored := *block
+	assert.Nil(t, chain.VerifyBlock(&stored))
+	assert.Nil(t, chain.VerifyBlock(&chain.Chain[0]))
+
+	stored.Tags = "forged"
+	err = stored.HashBlock()
+	assert.Nil(t, err)
+	assert.ErrorContains(t, chain.VerifyBlock(&stored), "signature verification failed")
+
+	// an unknown key can not verify the block
+	auditor := blockchain.NewBlockChain("")
+	_, other := _generateMockEd25519()
+	auditor.AddVerifier(other)
+	assert.NotNil(t, auditor.VerifyBlock(block))
+}
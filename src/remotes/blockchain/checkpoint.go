@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCheckpointKeyRetired - the checkpoint was not signed by the active key, it is not trusted
+var ErrCheckpointKeyRetired = errors.New("checkpoint not signed by the active key")
+
+// Checkpoint - last block verified by a validation, signed by the chain key
+// later validations verify only the blocks after it, linking them to the checkpoint block
+type Checkpoint struct {
+	ID uuid.UUID `gorm:"primarykey" json:"id"`
+
+	// Empty on the global chain
+	ChainID string    `gorm:"index;not null;default:''" json:"chain_id"`
+	SeqID   uint      `json:"seq_id"`
+	BlockID uuid.UUID `json:"block_id"`
+	Hash    string    `json:"hash"`
+
+	// Verified blocks by the validation that created the checkpoint
+	Verified int `json:"verified"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	SignatureScheme string `json:"signature_scheme"`
+	KeyFingerprint  string `json:"key_fingerprint"`
+	Signature       string `json:"signature"`
+}
+
+// Signable - canonical json of the checkpoint fields covered by the signature
+func (c *Checkpoint) Signable() ([]byte, error) {
+	return CanonicalJSON(map[string]interface{}{
+		"id":               c.ID.String(),
+		"chain_id":         c.ChainID,
+		"seq_id":           c.SeqID,
+		"block_id":         c.BlockID.String(),
+		"hash":             c.Hash,
+		"verified":         c.Verified,
+		"created_at":       FormatSignedAt(c.CreatedAt),
+		"signature_scheme": c.SignatureScheme,
+		"key_fingerprint":  c.KeyFingerprint,
+	})
+}
+
+// Matches - check the checkpoint is on the block, a rewritten block does not match
+func (c *Checkpoint) Matches(block *Block) error {
+	if block.ID != c.BlockID || block.SeqID != c.SeqID || block.ChainID != c.ChainID || block.Hash != c.Hash {
+		return fmt.Errorf("block %d [%s] differs from the checkpoint %s [%s]", block.SeqID, block.Hash, c.ID, c.Hash)
+	}
+	return nil
+}
+
+// NewCheckpoint - sign a checkpoint on the last verified block
+func (b *BlockChain) NewCheckpoint(block *Block, verified int) (*Checkpoint, error) {
+	signer := b.getSigner()
+	if signer == nil {
+		return nil, fmt.Errorf("not initialized")
+	}
+
+	checkpoint := &Checkpoint{
+		ID:              uuid.New(),
+		ChainID:         block.ChainID,
+		SeqID:           block.SeqID,
+		BlockID:         block.ID,
+		Hash:            block.Hash,
+		Verified:        verified,
+		CreatedAt:       time.Now().UTC().Truncate(SignedAtPrecision),
+		SignatureScheme: signer.Scheme(),
+		KeyFingerprint:  signer.Fingerprint(),
+	}
+
+	signable, err := checkpoint.Signable()
+	if err != nil {
+		return nil, fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	checkpoint.Signature, err = signer.Sign(signable)
+	if err != nil {
+		return nil, fmt.Errorf("signing checkpoint: %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// VerifyCheckpoint - check the checkpoint signature with the key that signed it
+// only the active key is trusted, a retired key could sign a checkpoint skipping rewritten blocks
+func (b *BlockChain) VerifyCheckpoint(checkpoint *Checkpoint) error {
+	if !b.keys.isActive(checkpoint.KeyFingerprint) {
+		return fmt.Errorf("%w: checkpoint %s key %s", ErrCheckpointKeyRetired, checkpoint.ID, checkpoint.KeyFingerprint)
+	}
+
+	verifier, err := b.keys.verifierOf(checkpoint.SignatureScheme, checkpoint.KeyFingerprint)
+	if err != nil {
+		return fmt.Errorf("getting verifier: %w", err)
+	}
+
+	signable, err := checkpoint.Signable()
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	err = verifier.Verify(signable, checkpoint.Signature)
+	if err != nil {
+		return fmt.Errorf("checking checkpoint %s signature: %w", checkpoint.ID, err)
+	}
+
+	return nil
+}
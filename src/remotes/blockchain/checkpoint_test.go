@@ -0,0 +1,65 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainCheckpoint(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < 6; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	checkpoint, err := chain.NewCheckpoint(&blocks[3], 4)
+	assert.Nil(t, err)
+	assert.Equal(t, blocks[3].ID, checkpoint.BlockID)
+	assert.Equal(t, signer.Fingerprint(), checkpoint.KeyFingerprint)
+
+	err = chain.VerifyCheckpoint(checkpoint)
+	assert.Nil(t, err)
+	assert.Nil(t, checkpoint.Matches(&blocks[3]))
+	assert.NotNil(t, checkpoint.Matches(&blocks[4]))
+
+	// the blocks after the checkpoint link to it
+	validator := chain.NewValidatorFrom(blocks[3])
+	err = validator.Validate(blocks[4:])
+	assert.Nil(t, err)
+	assert.Equal(t, 3, validator.Validated)
+
+	validator = chain.NewValidatorFrom(blocks[3])
+	err = validator.Validate(blocks[5:])
+	assert.ErrorContains(t, err, "chain is broken, last block is different")
+
+	forged := *checkpoint
+	forged.SeqID = 5
+	forged.BlockID = blocks[5].ID
+	forged.Hash = blocks[5].Hash
+	err = chain.VerifyCheckpoint(&forged)
+	assert.ErrorContains(t, err, "checking checkpoint")
+
+	// after a rotation the checkpoints of the retired key are not trusted
+	next, _ := _generateMockECDSA()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	err = chain.VerifyCheckpoint(checkpoint)
+	assert.ErrorIs(t, err, blockchain.ErrCheckpointKeyRetired)
+
+	last := chain.Chain[len(chain.Chain)-1]
+	checkpoint, err = chain.NewCheckpoint(&last, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, chain.VerifyCheckpoint(checkpoint))
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Digest algorithms supported to hash the blocks
+const (
+	HashSHA256     = "sha256"
+	HashSHA512     = "sha512"
+	HashSHA3_256   = "sha3-256"
+	HashBLAKE2b256 = "blake2b-256"
+)
+
+// DEFAULT_HASH_ALGORITHM - used by chains without configured algorithm and by blocks hashed before it was recorded
+const DEFAULT_HASH_ALGORITHM = HashSHA256
+
+// genesisHashes - hash of the genesis block on each algorithm
+// the genesis is deterministic, so its hash is well known
+var genesisHashes = map[string]string{
+	HashSHA256:     GENESIS_HASH_BLOCK,
+	HashSHA512:     "fbb2751223421693ce3bbc3e1be490302f51fd8d47cf046c95fb7c323c945082271dc9e8ada777cefb722444174acf1aa09d097e34f0b85d9f5d9301bf4d1e79",
+	HashSHA3_256:   "faeb61207bc2fd4892e70f4b6176a3eaad10db6c51d7806c9c2a06f04548e4f2",
+	HashBLAKE2b256: "6e5d2e4b82c015e7cc1189f6268bc632a5feb65fb18aa37972ad307cdbbd0b8d",
+}
+
+// newHash - hash function of the algorithm
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashSHA3_256:
+		return sha3.New256(), nil
+	case HashBLAKE2b256:
+		return blake2b.New256(nil)
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %s", algorithm)
+}
+
+// CheckHashAlgorithm - check the algorithm can be used to hash blocks
+func CheckHashAlgorithm(algorithm string) error {
+	_, err := newHash(algorithm)
+	return err
+}
+
+// Digest - hex digest of the data with the algorithm
+func Digest(algorithm string, data []byte) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// GenesisHash - well known hash of the genesis block on the algorithm
+func GenesisHash(algorithm string) string {
+	if algorithm == "" {
+		algorithm = DEFAULT_HASH_ALGORITHM
+	}
+	return genesisHashes[algorithm]
+}
+
+// blockHashAlgorithm - blocks hashed before the algorithm was recorded used sha256
+func blockHashAlgorithm(block *Block) string {
+	if block.HashAlgorithm == "" {
+		return DEFAULT_HASH_ALGORITHM
+	}
+	return block.HashAlgorithm
+}
@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ReadBlocks - decode blocks from a json array or from newline delimited json
+func ReadBlocks(r io.Reader) ([]Block, error) {
+	reader := bufio.NewReader(r)
+
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading blocks: %w", err)
+	}
+
+	var blocks []Block
+	decoder := json.NewDecoder(reader)
+
+	if first == '[' {
+		err = decoder.Decode(&blocks)
+		if err != nil {
+			return nil, fmt.Errorf("decoding blocks array: %w", err)
+		}
+		return blocks, nil
+	}
+
+	for {
+		var block Block
+		err = decoder.Decode(&block)
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding block %d: %w", len(blocks), err)
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// WriteBlock - encode the block as a json line
+func WriteBlock(w io.Writer, block *Block) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("encoding block %d: %w", block.SeqID, err)
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// firstNonSpace - peek the first relevant byte without consuming it
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return b, reader.UnreadByte()
+	}
+}
@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const TRANSACTION_CODE_MERKLE_ROOT = "merkle_root"
+const TRANSACTION_CODE_ENTRIES = "entries"
+const BATCH_SYSTEM_ID = "batch"
+
+// Entry - a log stored outside of the block, committed by the block merkle root
+type Entry struct {
+	ID uuid.UUID `gorm:"primarykey"`
+
+	// Block that commits this entry on its merkle root
+	BlockID uuid.UUID `gorm:"index"`
+
+	// Position of the entry on the block merkle tree
+	Index int
+
+	// TransactionStr its the payload to be hashed
+	TransactionStr string
+	// Transaction its the variable to be manipulated
+	Transaction map[string]interface{} `gorm:"-"`
+
+	// Metadata used to filter entries by a system in database
+	SystemID string `gorm:"index;uniqueIndex:idx_entries_system_idempotency_key,priority:1"`
+
+	// Key sent by the client to not store the same log twice, unique by system when setted
+	IdempotencyKey string `gorm:"not null;default:'';uniqueIndex:idx_entries_system_idempotency_key,priority:2,where:idempotency_key <> ''"`
+
+	Tags string
+
+	// Merkle leaf hash from the payload and metadata
+	Hash string
+
+	CreatedAt time.Time
+}
+
+func NewEntry(systemID string, transaction map[string]interface{}, tags ...string) *Entry {
+	return &Entry{
+		ID:          uuid.New(),
+		Transaction: transaction,
+		SystemID:    systemID,
+		Tags:        strings.Join(tags, ";"),
+	}
+}
+
+// Hashable - returns the leaf data, json keeps the fields unambiguous
+func (e *Entry) Hashable() []byte {
+	data, _ := json.Marshal([]string{e.ID.String(), e.SystemID, e.Tags, e.TransactionStr})
+	return data
+}
+
+// HashEntry - serialize the transaction and calc the leaf hash
+func (e *Entry) HashEntry() error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.Transaction == nil {
+		e.Transaction = map[string]interface{}{}
+	}
+
+	e.Transaction[TRANSACTION_CODE_SYSTEM_ID] = e.SystemID
+
+	t, err := CanonicalJSON(&e.Transaction)
+	if err != nil {
+		return fmt.Errorf("marshaling transaction: %w", err)
+	}
+
+	e.TransactionStr = string(t)
+	e.Hash = e.CalcHash()
+
+	return nil
+}
+
+// CalcHash - calc the merkle leaf hash of the entry
+func (e *Entry) CalcHash() string {
+	return MerkleLeaf(e.Hashable())
+}
+
+// Unpack - unpack the payload stored on transactionString to transaction struct
+func (e *Entry) Unpack() error {
+	err := json.Unmarshal([]byte(e.TransactionStr), &e.Transaction)
+	if err != nil {
+		return fmt.Errorf("parsing the transaction string into a struct: %w", err)
+	}
+
+	return nil
+}
+
+// NewBatchBlock - create a block committing the hashed entries on a merkle root
+// the entries are linked to the block and indexed by their position
+func NewBatchBlock(entries []*Entry) (*Block, error) {
+	block := NewBlock(BATCH_SYSTEM_ID, nil)
+
+	leaves := make([]string, len(entries))
+	for i, entry := range entries {
+		entry.BlockID = block.ID
+		entry.Index = i
+		leaves[i] = entry.Hash
+	}
+
+	root, err := MerkleRoot(leaves)
+	if err != nil {
+		return nil, fmt.Errorf("calculating merkle root: %w", err)
+	}
+
+	block.Transaction = map[string]interface{}{
+		TRANSACTION_CODE_MERKLE_ROOT: root,
+		TRANSACTION_CODE_ENTRIES:     len(entries),
+	}
+
+	return block, nil
+}
+
+// MerkleRoot - read the merkle root from the signed payload, empty when the block is not a batch
+func (b *Block) MerkleRoot() string {
+	var payload struct {
+		SystemID   string `json:"system_id"`
+		MerkleRoot string `json:"merkle_root"`
+	}
+
+	err := json.Unmarshal([]byte(b.TransactionStr), &payload)
+	if err != nil || payload.SystemID != BATCH_SYSTEM_ID {
+		return ""
+	}
+
+	return payload.MerkleRoot
+}
+
+// VerifyEntries - check the entries, ordered by index, against the block merkle root
+func (b *Block) VerifyEntries(entries []Entry) error {
+	root := b.MerkleRoot()
+	if root == "" {
+		return fmt.Errorf("block is not a batch")
+	}
+
+	leaves := make([]string, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		if entry.BlockID != b.ID || entry.Index != i {
+			return fmt.Errorf("entry %s is not at position %d of block %s", entry.ID, i, b.ID)
+		}
+
+		hash := entry.CalcHash()
+		if entry.Hash != hash {
+			return fmt.Errorf("invalid hash entry %s: %v != %v", entry.ID, entry.Hash, hash)
+		}
+		leaves[i] = hash
+	}
+
+	calculated, err := MerkleRoot(leaves)
+	if err != nil {
+		return fmt.Errorf("calculating merkle root: %w", err)
+	}
+
+	if calculated != root {
+		return fmt.Errorf("invalid merkle root: %v != %v", root, calculated)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package blockchain_test
+
+import (
+	"fmt"
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func _mockEntries(size int) []*blockchain.Entry {
+	entries := make([]*blockchain.Entry, size)
+	for i := range entries {
+		entries[i] = blockchain.NewEntry("sauron", map[string]interface{}{
+			"table": "user",
+			"to": map[string]interface{}{
+				"user_id": fmt.Sprint(i),
+			},
+		}, "cdc")
+		entries[i].HashEntry()
+	}
+	return entries
+}
+
+func TestMerkleRoot(t *testing.T) {
+	leaves := []string{}
+	for _, entry := range _mockEntries(5) {
+		leaves = append(leaves, entry.Hash)
+	}
+
+	root, err := blockchain.MerkleRoot(leaves)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, root)
+
+	single, err := blockchain.MerkleRoot(leaves[:1])
+	assert.Nil(t, err)
+	assert.Equal(t, leaves[0], single, "the root of a single leaf is the leaf")
+
+	swapped := []string{leaves[1], leaves[0], leaves[2], leaves[3], leaves[4]}
+	swappedRoot, err := blockchain.MerkleRoot(swapped)
+	assert.Nil(t, err)
+	assert.NotEqual(t, root, swappedRoot)
+
+	_, err = blockchain.MerkleRoot(nil)
+	assert.Error(t, err)
+}
+
+func TestChainBatchBlock(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	entries := _mockEntries(3)
+	batchBlock, err := blockchain.NewBatchBlock(entries)
+	assert.Nil(t, err)
+
+	addedBlock, err := chain.AppendBlock(batchBlock)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, addedBlock.MerkleRoot())
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	stored := make([]blockchain.Entry, len(entries))
+	for i, entry := range entries {
+		assert.Equal(t, addedBlock.ID, entry.BlockID)
+		stored[i] = *entry
+	}
+
+	err = addedBlock.VerifyEntries(stored)
+	assert.Nil(t, err)
+
+	stored[1].TransactionStr = `{"system_id":"sauron","table":"admin"}`
+	err = addedBlock.VerifyEntries(stored)
+	assert.ErrorContains(t, err, "invalid hash entry")
+
+	stored[1] = *entries[1]
+	stored[0], stored[2] = stored[2], stored[0]
+	err = addedBlock.VerifyEntries(stored)
+	assert.ErrorContains(t, err, "is not at position")
+}
@@ -0,0 +1,211 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const TRANSACTION_CODE_KEY_ROTATION = "key_rotation"
+const KEY_ROTATION_SYSTEM_ID = "key_rotation"
+
+// KeyRotation - payload of a key rotation block, announces the next signing key
+type KeyRotation struct {
+	Scheme      string `json:"scheme"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type rotation struct {
+	seqID       uint
+	fingerprint string
+	// signing date of the rotation block, zero when unknown
+	at time.Time
+}
+
+// keyRing - the keys trusted to verify blocks
+// configured keys are the trust root, the others are learned from rotation blocks
+type keyRing struct {
+	mu        sync.RWMutex
+	verifiers map[string]Verifier // by fingerprint
+	schemes   map[string]Verifier // first key by scheme, used on blocks without fingerprint
+	rotations []rotation          // ordered by seqID
+}
+
+func newKeyRing() *keyRing {
+	return &keyRing{
+		verifiers: map[string]Verifier{},
+		schemes:   map[string]Verifier{},
+	}
+}
+
+func (k *keyRing) add(verifier Verifier) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.verifiers[verifier.Fingerprint()] = verifier
+	if _, ok := k.schemes[verifier.Scheme()]; !ok {
+		k.schemes[verifier.Scheme()] = verifier
+	}
+}
+
+func (k *keyRing) list() []Verifier {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	verifiers := make([]Verifier, 0, len(k.verifiers))
+	for _, verifier := range k.verifiers {
+		verifiers = append(verifiers, verifier)
+	}
+	return verifiers
+}
+
+func (k *keyRing) size() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.verifiers)
+}
+
+// verifierFor - return the verifier able to check the block signature
+// blocks without fingerprint were signed before rotations existed, so they use the scheme root key
+func (k *keyRing) verifierFor(block *Block) (Verifier, error) {
+	return k.verifierOf(blockScheme(block), block.KeyFingerprint)
+}
+
+// verifierOf - verifier of the key with the fingerprint, the scheme root key when the fingerprint is empty
+func (k *keyRing) verifierOf(scheme, fingerprint string) (Verifier, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if fingerprint == "" {
+		verifier, ok := k.schemes[scheme]
+		if !ok {
+			return nil, fmt.Errorf("no verifier for scheme %s", scheme)
+		}
+		return verifier, nil
+	}
+
+	verifier, ok := k.verifiers[fingerprint]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", fingerprint)
+	}
+	return verifier, nil
+}
+
+// addRotation - record the key activated at seqID, keeping the rotations ordered
+func (k *keyRing) addRotation(seqID uint, fingerprint string, at time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := len(k.rotations)
+	for i > 0 && k.rotations[i-1].seqID >= seqID {
+		if k.rotations[i-1].seqID == seqID {
+			return
+		}
+		i--
+	}
+
+	k.rotations = append(k.rotations, rotation{})
+	copy(k.rotations[i+1:], k.rotations[i:])
+	k.rotations[i] = rotation{seqID: seqID, fingerprint: fingerprint, at: at}
+}
+
+// activeAt - fingerprint of the key that must sign the block at seqID
+// empty when no rotation happened before it
+func (k *keyRing) activeAt(seqID uint) string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	active := ""
+	for _, r := range k.rotations {
+		if r.seqID >= seqID {
+			break
+		}
+		active = r.fingerprint
+	}
+	return active
+}
+
+// isActive - the key signs the new blocks of the global chain: the last rotated key, or any configured key before the first rotation
+func (k *keyRing) isActive(fingerprint string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.rotations) > 0 {
+		return k.rotations[len(k.rotations)-1].fingerprint == fingerprint
+	}
+
+	_, ok := k.verifiers[fingerprint]
+	return ok
+}
+
+// generation - position of the key on the rotations, configured keys are 0
+// used on sub-chains, that have no rotation blocks, to refuse keys retired before the last used one
+func (k *keyRing) generation(fingerprint string) int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for i, r := range k.rotations {
+		if r.fingerprint == fingerprint {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// generationAt - generation of the global key in effect at the signing date
+// used on sub-chains, a block signed after a rotation can not use a key retired by it
+func (k *keyRing) generationAt(at time.Time) int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if at.IsZero() {
+		return 0
+	}
+
+	generation := 0
+	for i, r := range k.rotations {
+		if !r.at.IsZero() && !r.at.After(at) {
+			generation = i + 1
+		}
+	}
+	return generation
+}
+
+// NewKeyRotationBlock - create the block that announces the next signing key
+// it must be chained and signed by the outgoing key
+func NewKeyRotationBlock(next Verifier) *Block {
+	return NewBlock(KEY_ROTATION_SYSTEM_ID, map[string]interface{}{
+		TRANSACTION_CODE_KEY_ROTATION: KeyRotation{
+			Scheme:      next.Scheme(),
+			PublicKey:   next.PublicKey(),
+			Fingerprint: next.Fingerprint(),
+		},
+	})
+}
+
+// IsKeyRotation - check on the signed payload if the block announces a new key
+func (b *Block) IsKeyRotation() bool {
+	_, err := b.KeyRotation()
+	return err == nil
+}
+
+// KeyRotation - read the announced key from the signed payload
+func (b *Block) KeyRotation() (*KeyRotation, error) {
+	var payload struct {
+		SystemID string       `json:"system_id"`
+		Rotation *KeyRotation `json:"key_rotation"`
+	}
+
+	err := json.Unmarshal([]byte(b.TransactionStr), &payload)
+	if err != nil {
+		return nil, fmt.Errorf("parsing transaction: %w", err)
+	}
+
+	if payload.SystemID != KEY_ROTATION_SYSTEM_ID || payload.Rotation == nil {
+		return nil, fmt.Errorf("block is not a key rotation")
+	}
+
+	return payload.Rotation, nil
+}
@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Domain separation between leaves and nodes, avoid a node being presented as a leaf
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleLeaf - hash of a leaf data
+func MerkleLeaf(data []byte) string {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+	return hex.EncodeToString(hash[:])
+}
+
+func merkleNode(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, merkleNodePrefix)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+
+	hash := sha256.Sum256(buf)
+	return hash[:]
+}
+
+// merkleLevel - hash the nodes in pairs, an odd node is carried to the next level
+func merkleLevel(nodes [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(nodes)+1)/2)
+	for i := 0; i < len(nodes); i += 2 {
+		if i+1 == len(nodes) {
+			next = append(next, nodes[i])
+			continue
+		}
+		next = append(next, merkleNode(nodes[i], nodes[i+1]))
+	}
+	return next
+}
+
+func decodeLeaves(leaves []string) ([][]byte, error) {
+	nodes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		node, err := hex.DecodeString(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("decoding leaf %d: %w", i, err)
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// MerkleRoot - root of the tree given the leaf hashes in order
+func MerkleRoot(leaves []string) (string, error) {
+	if len(leaves) == 0 {
+		return "", fmt.Errorf("empty tree")
+	}
+
+	nodes, err := decodeLeaves(leaves)
+	if err != nil {
+		return "", err
+	}
+
+	for len(nodes) > 1 {
+		nodes = merkleLevel(nodes)
+	}
+
+	return hex.EncodeToString(nodes[0]), nil
+}
+
+// MerkleStep - sibling hash used to climb one level of the tree
+type MerkleStep struct {
+	Hash string `json:"hash"`
+	// Left its true when the sibling is on the left side
+	Left bool `json:"left"`
+}
+
+// MerkleProof - path from the leaf at index to the root
+// levels where the node is carried up without sibling have no step
+func MerkleProof(leaves []string, index int) ([]MerkleStep, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf %d out of tree with %d leaves", index, len(leaves))
+	}
+
+	nodes, err := decodeLeaves(leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []MerkleStep{}
+	for len(nodes) > 1 {
+		sibling := index ^ 1
+		if sibling < len(nodes) {
+			path = append(path, MerkleStep{
+				Hash: hex.EncodeToString(nodes[sibling]),
+				Left: sibling < index,
+			})
+		}
+
+		nodes = merkleLevel(nodes)
+		index /= 2
+	}
+
+	return path, nil
+}
+
+// VerifyMerkleProof - climb the path from the leaf and compare with the root
+func VerifyMerkleProof(leaf string, path []MerkleStep, root string) error {
+	node, err := hex.DecodeString(leaf)
+	if err != nil {
+		return fmt.Errorf("decoding leaf: %w", err)
+	}
+
+	for i, step := range path {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return fmt.Errorf("decoding step %d: %w", i, err)
+		}
+
+		if step.Left {
+			node = merkleNode(sibling, node)
+		} else {
+			node = merkleNode(node, sibling)
+		}
+	}
+
+	if hex.EncodeToString(node) != root {
+		return fmt.Errorf("invalid merkle proof: %x != %v", node, root)
+	}
+
+	return nil
+}
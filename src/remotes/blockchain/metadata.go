@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMetadataTampered - the block metadata differs from the signed metadata envelope
+var ErrMetadataTampered = errors.New("block metadata tampered")
+
+// MetadataEnvelope - block metadata covered by the signature
+// stored blocks keep only its digest, the envelope is rebuilt from the block fields
+type MetadataEnvelope struct {
+	ChainID         string `json:"chain_id,omitempty"`
+	SystemID        string `json:"system_id"`
+	Tags            string `json:"tags"`
+	CreatedAt       string `json:"created_at"`
+	SignatureScheme string `json:"signature_scheme"`
+	KeyFingerprint  string `json:"key_fingerprint"`
+}
+
+// Metadata - envelope with the current block metadata
+func (b *Block) Metadata() MetadataEnvelope {
+	return MetadataEnvelope{
+		ChainID:         b.ChainID,
+		SystemID:        b.SystemID,
+		Tags:            b.Tags,
+		CreatedAt:       FormatSignedAt(b.CreatedAt),
+		SignatureScheme: b.SignatureScheme,
+		KeyFingerprint:  b.KeyFingerprint,
+	}
+}
+
+// CalcMetadataHash - digest of the canonical metadata envelope with the block digest algorithm
+func (b *Block) CalcMetadataHash() (string, error) {
+	envelope, err := CanonicalJSON(b.Metadata())
+	if err != nil {
+		return "", fmt.Errorf("encoding metadata envelope: %w", err)
+	}
+
+	return Digest(blockHashAlgorithm(b), envelope)
+}
+
+// HasMetadataEnvelope - check if the block signature covers its metadata
+func (b *Block) HasMetadataEnvelope() bool {
+	return b.MetadataHash != ""
+}
+
+// CheckMetadata - check the metadata is the one signed on the envelope
+// blocks without envelope have their metadata unprotected
+func (b *Block) CheckMetadata() error {
+	if !b.HasMetadataEnvelope() {
+		return nil
+	}
+
+	hash, err := b.CalcMetadataHash()
+	if err != nil {
+		return err
+	}
+
+	if hash != b.MetadataHash {
+		return fmt.Errorf("%w! blockID: %s seqBlock: %d system: [%s] tags: [%s]", ErrMetadataTampered, b.ID.String(), b.SeqID, b.SystemID, b.Tags)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package blockchain_test
+
+import (
+	"errors"
+	"logger/remotes/blockchain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainMetadataEnvelope(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+	chain.SetSignMetadata(true)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	addedBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.True(t, addedBlock.HasMetadataEnvelope())
+	assert.False(t, addedBlock.CreatedAt.IsZero())
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	tampers := map[string]func(b *blockchain.Block){
+		"system":     func(b *blockchain.Block) { b.SystemID = "gandalf" },
+		"tags":       func(b *blockchain.Block) { b.Tags = "cdc;admin" },
+		"created at": func(b *blockchain.Block) { b.CreatedAt = b.CreatedAt.Add(-time.Hour) },
+		"signer":     func(b *blockchain.Block) { b.SignatureScheme = blockchain.SchemePGP },
+	}
+
+	for name, tamper := range tampers {
+		block := *addedBlock
+		tamper(&block)
+		chain.Chain[1] = block
+
+		err = chain.Validate()
+		assert.True(t, errors.Is(err, blockchain.ErrMetadataTampered), name)
+	}
+
+	// the envelope digest can not be replaced without breaking the signature
+	block := *addedBlock
+	block.SystemID = "gandalf"
+	block.MetadataHash, err = block.CalcMetadataHash()
+	assert.Nil(t, err)
+	chain.Chain[1] = block
+
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "checking signature")
+	assert.False(t, errors.Is(err, blockchain.ErrMetadataTampered))
+
+	// neither stripped
+	block = *addedBlock
+	block.MetadataHash = ""
+	chain.Chain[1] = block
+
+	err = chain.Validate()
+	assert.ErrorContains(t, err, "checking signature")
+}
@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// preimageDomain - prefix of the preimage, avoids collisions with data hashed by other formats
+const preimageDomain = "blockchain-logger/block"
+
+// Preimage - returns the bytes to be hashed for the block hash version
+func (b *Block) Preimage() []byte {
+	if b.HashVersion < HASH_VERSION_PREIMAGE {
+		return []byte(b.Hashable())
+	}
+
+	var buf bytes.Buffer
+	writeField(&buf, []byte(preimageDomain))
+	writeField(&buf, binary.BigEndian.AppendUint32(nil, uint32(b.HashVersion)))
+	writeField(&buf, []byte(blockHashAlgorithm(b)))
+	writeField(&buf, binary.BigEndian.AppendUint64(nil, uint64(b.SeqID)))
+	writeField(&buf, b.ID[:])
+	writeField(&buf, b.LastBlockID[:])
+	writeField(&buf, []byte(b.LastBlockHash))
+	writeField(&buf, []byte(b.SystemID))
+	writeField(&buf, []byte(b.Tags))
+	writeField(&buf, []byte(b.TransactionStr))
+	writeField(&buf, []byte(b.HashedAt))
+	writeField(&buf, []byte(FormatSignedAt(b.SignedAt)))
+
+	// appended only on sub-chain blocks, keeping the global chain preimage
+	if b.ChainID != "" {
+		writeField(&buf, []byte(b.ChainID))
+	}
+
+	return buf.Bytes()
+}
+
+// writeField - write the field prefixed by its length as a big endian uint32
+func writeField(buf *bytes.Buffer, field []byte) {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+	buf.Write(size[:])
+	buf.Write(field)
+}
+
+// SignedAtPrecision - the signing date is hashed with the precision kept by the database
+const SignedAtPrecision = time.Microsecond
+
+// FormatSignedAt - representation of the signing date on the preimage, in UTC
+func FormatSignedAt(t time.Time) string {
+	return t.UTC().Truncate(SignedAtPrecision).Format(time.RFC3339Nano)
+}
@@ -0,0 +1,76 @@
+package blockchain_test
+
+import (
+	"encoding/json"
+	"logger/remotes/blockchain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockPreimage(t *testing.T) {
+	a := blockchain.Block{TransactionStr: `{"a":"1.2"}`, HashedAt: "3", HashVersion: blockchain.HASH_VERSION_CANONICAL}
+	b := blockchain.Block{TransactionStr: `{"a":"1`, HashedAt: `2"}.3`, HashVersion: blockchain.HASH_VERSION_CANONICAL}
+	b.ID, b.LastBlockID = a.ID, a.LastBlockID
+
+	// the dotted encoding can not tell the fields apart
+	assert.Equal(t, a.CalcHash(), b.CalcHash())
+
+	a.HashVersion = blockchain.HASH_VERSION_PREIMAGE
+	b.HashVersion = blockchain.HASH_VERSION_PREIMAGE
+	assert.NotEqual(t, a.CalcHash(), b.CalcHash())
+}
+
+func TestChainPreimageMetadata(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	addedBlock, err := chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+	assert.Equal(t, blockchain.HASH_VERSION_PREIMAGE, addedBlock.HashVersion)
+
+	// the block survives the precision and location kept by the database
+	stored := *addedBlock
+	stored.SignedAt = stored.SignedAt.In(time.FixedZone("BRT", -3*60*60))
+	data, err := json.Marshal(&stored)
+	assert.Nil(t, err)
+	var decoded blockchain.Block
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, addedBlock.Hash, decoded.CalcHash())
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+
+	tampers := map[string]func(b *blockchain.Block){
+		"system":    func(b *blockchain.Block) { b.SystemID = "gandalf" },
+		"tags":      func(b *blockchain.Block) { b.Tags = "cdc;admin" },
+		"signed at": func(b *blockchain.Block) { b.SignedAt = b.SignedAt.Add(time.Second) },
+	}
+
+	for name, tamper := range tampers {
+		block := *addedBlock
+		tamper(&block)
+		chain.Chain[1] = block
+
+		err = chain.Validate()
+		assert.ErrorContains(t, err, "invalid hash block", name)
+	}
+
+	// legacy blocks do not protect the metadata
+	block := *addedBlock
+	block.HashVersion = blockchain.HASH_VERSION_CANONICAL
+	block.Hash = block.CalcHash()
+	block.Signature, err = signer.Sign([]byte(block.Signable()))
+	assert.Nil(t, err)
+	block.SystemID = "gandalf"
+	chain.Chain[1] = block
+
+	err = chain.Validate()
+	assert.Nil(t, err)
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+// InclusionProof - everything a third party needs to verify offline that a log is on the chain
+type InclusionProof struct {
+	// Entry and MerklePath are setted when the log is an entry of a batch block
+	Entry      *Entry       `json:",omitempty"`
+	MerklePath []MerkleStep `json:",omitempty"`
+
+	// Block that contains the log, with its signature
+	Block Block
+
+	// Key that signed the block
+	Scheme         string
+	KeyFingerprint string
+	PublicKey      string
+
+	// Blocks chained after the block until the head, in order
+	// each one links to the previous by LastBlockID and LastBlockHash
+	Links []Block
+	// More blocks are chained after the last link, the proof is bounded and its head is not the chain head
+	More bool `json:",omitempty"`
+}
+
+// Head - the last block covered by the proof
+func (p *InclusionProof) Head() Block {
+	if len(p.Links) == 0 {
+		return p.Block
+	}
+	return p.Links[len(p.Links)-1]
+}
+
+// NewInclusionProof - build the proof of a block, entries must be all the block entries ordered by index
+func (b *BlockChain) NewInclusionProof(block Block, entry *Entry, entries []Entry, links []Block) (*InclusionProof, error) {
+	verifier, err := b.keys.verifierFor(&block)
+	if err != nil {
+		return nil, fmt.Errorf("getting block key: %w", err)
+	}
+
+	proof := &InclusionProof{
+		Block:          block,
+		Scheme:         verifier.Scheme(),
+		KeyFingerprint: verifier.Fingerprint(),
+		PublicKey:      verifier.PublicKey(),
+		Links:          links,
+	}
+
+	if entry == nil {
+		return proof, nil
+	}
+
+	leaves := make([]string, len(entries))
+	for i := range entries {
+		leaves[i] = entries[i].Hash
+	}
+
+	proof.MerklePath, err = MerkleProof(leaves, entry.Index)
+	if err != nil {
+		return nil, fmt.Errorf("generating merkle proof: %w", err)
+	}
+	proof.Entry = entry
+
+	return proof, nil
+}
+
+// Verify - check the proof using the trusted keys
+// the block and the head must be signed by known keys, keys announced by rotation blocks on the links are learned
+func (p *InclusionProof) Verify(verifiers ...Verifier) error {
+	keys := newKeyRing()
+	for _, verifier := range verifiers {
+		keys.add(verifier)
+	}
+
+	if p.Entry != nil {
+		hash := p.Entry.CalcHash()
+		if p.Entry.Hash != hash {
+			return fmt.Errorf("invalid hash entry: %v != %v", p.Entry.Hash, hash)
+		}
+
+		if p.Entry.BlockID != p.Block.ID {
+			return fmt.Errorf("entry %s is not linked to block %s", p.Entry.ID, p.Block.ID)
+		}
+
+		err := VerifyMerkleProof(hash, p.MerklePath, p.Block.MerkleRoot())
+		if err != nil {
+			return fmt.Errorf("verifying entry inclusion: %w", err)
+		}
+	}
+
+	err := verifyBlock(keys, &p.Block)
+	if err != nil {
+		return fmt.Errorf("verifying block: %w", err)
+	}
+
+	last := p.Block
+	for i := range p.Links {
+		link := p.Links[i]
+
+		hash := link.CalcHash()
+		if link.Hash != hash {
+			return fmt.Errorf("invalid hash on link %d: %v != %v", link.SeqID, link.Hash, hash)
+		}
+
+		if link.LastBlockID != last.ID || link.LastBlockHash != last.Hash || link.SeqID != last.SeqID+1 {
+			return fmt.Errorf("chain is broken on link %d, previous block is %d", link.SeqID, last.SeqID)
+		}
+
+		if link.IsKeyRotation() {
+			err = verifyBlock(keys, &link)
+			if err != nil {
+				return fmt.Errorf("verifying rotation link %d: %w", link.SeqID, err)
+			}
+
+			rotation, _ := link.KeyRotation()
+			verifier, err := NewVerifier(rotation.Scheme, rotation.PublicKey)
+			if err != nil || verifier.Fingerprint() != rotation.Fingerprint {
+				return fmt.Errorf("invalid key announced on link %d", link.SeqID)
+			}
+			keys.add(verifier)
+		}
+
+		last = link
+	}
+
+	err = verifyBlock(keys, &last)
+	if err != nil {
+		return fmt.Errorf("verifying head: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleProof(t *testing.T) {
+	for size := 1; size <= 7; size++ {
+		leaves := []string{}
+		for _, entry := range _mockEntries(size) {
+			leaves = append(leaves, entry.Hash)
+		}
+
+		root, err := blockchain.MerkleRoot(leaves)
+		assert.Nil(t, err)
+
+		for i := range leaves {
+			path, err := blockchain.MerkleProof(leaves, i)
+			assert.Nil(t, err)
+
+			err = blockchain.VerifyMerkleProof(leaves[i], path, root)
+			assert.Nil(t, err, "size %d leaf %d", size, i)
+
+			if size > 1 {
+				err = blockchain.VerifyMerkleProof(leaves[(i+1)%size], path, root)
+				assert.Error(t, err, "size %d leaf %d", size, i)
+			}
+		}
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	entries := _mockEntries(5)
+	batchBlock, err := blockchain.NewBatchBlock(entries)
+	assert.Nil(t, err)
+	_, err = chain.AppendBlock(batchBlock)
+	assert.Nil(t, err)
+
+	next, _ := _generateMockECDSA()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	_, err = chain.AppendBlock(_mockBlock())
+	assert.Nil(t, err)
+
+	stored := make([]blockchain.Entry, len(entries))
+	for i, entry := range entries {
+		stored[i] = *entry
+	}
+
+	links := append([]blockchain.Block{}, chain.Chain[2:]...)
+	entry := stored[3]
+	proof, err := chain.NewInclusionProof(chain.Chain[1], &entry, stored, links)
+	assert.Nil(t, err)
+	assert.Equal(t, signer.Fingerprint(), proof.KeyFingerprint)
+	assert.Equal(t, chain.Chain[3].ID, proof.Head().ID)
+
+	// the auditor only trusts the key that signed the block
+	err = proof.Verify(verifier)
+	assert.Nil(t, err)
+
+	// a bounded proof ends on a signed link before the chain head
+	bounded, err := chain.NewInclusionProof(chain.Chain[1], &entry, stored, links[:1])
+	assert.Nil(t, err)
+	bounded.More = true
+	assert.Equal(t, chain.Chain[2].ID, bounded.Head().ID)
+	err = bounded.Verify(verifier)
+	assert.Nil(t, err)
+
+	proof.Entry.TransactionStr = `{"system_id":"sauron","table":"admin"}`
+	proof.Entry.Hash = proof.Entry.CalcHash()
+	err = proof.Verify(verifier)
+	assert.ErrorContains(t, err, "verifying entry inclusion")
+
+	proof.Entry = &stored[3]
+	proof.Links[1].LastBlockHash = proof.Links[0].LastBlockHash
+	err = proof.Verify(verifier)
+	assert.ErrorContains(t, err, "invalid hash on link")
+}
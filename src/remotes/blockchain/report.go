@@ -0,0 +1,246 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of issues found by Diagnose
+const (
+	IssueDuplicateSeqID = "duplicate_seq_id"
+	IssueGap            = "gap"
+	IssueOrphan         = "orphan"
+	IssueFork           = "fork"
+	IssueBrokenLink     = "broken_link"
+	IssueSignature      = "signature"
+	IssueMetadata       = "metadata"
+	IssueHash           = "hash"
+)
+
+// ChainIssue - a problem found on a block
+type ChainIssue struct {
+	Kind    string
+	SeqID   uint
+	BlockID uuid.UUID
+	// Related blocks, the duplicated ones or the children of a fork
+	Related []uuid.UUID `json:",omitempty"`
+	Error   string
+}
+
+// ChainReport - every issue found on the blocks of a chain
+type ChainReport struct {
+	ChainID    string
+	Valid      bool
+	Blocks     int
+	FirstSeqID uint
+	LastSeqID  uint
+
+	// FirstBrokenSeqID its the lowest seqID with any issue
+	FirstBrokenSeqID *uint `json:",omitempty"`
+
+	Issues []ChainIssue
+}
+
+func (r *ChainReport) add(kind string, block *Block, related []uuid.UUID, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ChainIssue{
+		Kind:    kind,
+		SeqID:   block.SeqID,
+		BlockID: block.ID,
+		Related: related,
+		Error:   fmt.Sprintf(format, args...),
+	})
+
+	if r.FirstBrokenSeqID == nil || block.SeqID < *r.FirstBrokenSeqID {
+		seqID := block.SeqID
+		r.FirstBrokenSeqID = &seqID
+	}
+}
+
+// Count - number of issues of the kind
+func (r *ChainReport) Count(kind string) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Diagnose - walk all the blocks of a chain and report every issue instead of stopping on the first one
+// the blocks can be unordered and have duplicates, the parent of the lowest seqID is not required
+func (b *BlockChain) Diagnose(blocks []Block) *ChainReport {
+	d := b.NewDiagnoser(nil)
+	_ = d.Diagnose(blocks)
+	return d.Report()
+}
+
+// ChainDiagnoser - diagnose a chain received in batches ordered by seqID
+// only the blocks of the last seqID are kept between the batches, as parents of the next one
+type ChainDiagnoser struct {
+	chain *BlockChain
+	// lookup reads a parent out of the batches, nil when it does not exist
+	lookup func(id uuid.UUID) (*Block, error)
+	report *ChainReport
+	last   []Block
+}
+
+// NewDiagnoser - diagnoser reading the parents out of the received batches by the lookup, nil reports them as orphans
+func (b *BlockChain) NewDiagnoser(lookup func(id uuid.UUID) (*Block, error)) *ChainDiagnoser {
+	return &ChainDiagnoser{
+		chain:  b,
+		lookup: lookup,
+		report: &ChainReport{Issues: []ChainIssue{}},
+	}
+}
+
+// Diagnose - report the issues of the next batch, every block of a seqID must come on the same batch
+func (d *ChainDiagnoser) Diagnose(blocks []Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	r := d.report
+
+	sorted := append([]Block{}, blocks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SeqID < sorted[j].SeqID
+	})
+
+	if r.Blocks == 0 {
+		r.FirstSeqID = sorted[0].SeqID
+	}
+	r.Blocks += len(sorted)
+	r.LastSeqID = sorted[len(sorted)-1].SeqID
+	for i := 0; i < len(sorted) && r.ChainID == ""; i++ {
+		if !sorted[i].IsGenesis() {
+			r.ChainID = sorted[i].ChainID
+		}
+	}
+
+	// the last blocks of the previous batch are the parents of the first ones
+	window := append(append([]Block{}, d.last...), sorted...)
+	previous := len(d.last)
+
+	byID := map[uuid.UUID]*Block{}
+	bySeq := map[uint][]*Block{}
+	children := map[uuid.UUID][]*Block{}
+	for i := range window {
+		block := &window[i]
+		byID[block.ID] = block
+		if i >= previous {
+			bySeq[block.SeqID] = append(bySeq[block.SeqID], block)
+		}
+		if !block.IsGenesis() {
+			children[block.LastBlockID] = append(children[block.LastBlockID], block)
+		}
+	}
+
+	// the children of the previous last blocks are on this batch
+	for i := 0; i < previous; i++ {
+		if next := children[window[i].ID]; len(next) > 1 {
+			r.add(IssueFork, &window[i], blockIDs(next), "%d blocks chained to the block", len(next))
+		}
+	}
+
+	lastSeqID := r.LastSeqID
+	for i := previous; i < len(window); i++ {
+		block := &window[i]
+
+		if same := bySeq[block.SeqID]; len(same) > 1 && same[0] == block {
+			r.add(IssueDuplicateSeqID, block, blockIDs(same[1:]), "%d blocks with seqID %d", len(same), block.SeqID)
+		}
+
+		if i > 0 && window[i-1].SeqID+1 < block.SeqID {
+			r.add(IssueGap, block, nil, "missing seqID %d - %d", window[i-1].SeqID+1, block.SeqID-1)
+		}
+
+		// the children of the last blocks come on the next batch
+		if next := children[block.ID]; len(next) > 1 && block.SeqID != lastSeqID {
+			r.add(IssueFork, block, blockIDs(next), "%d blocks chained to the block", len(next))
+		}
+
+		d.chain.diagnoseBlock(r, block)
+
+		if block.IsGenesis() || block.SeqID == r.FirstSeqID {
+			continue
+		}
+
+		parent, ok := byID[block.LastBlockID]
+		if !ok && d.lookup != nil {
+			var err error
+			parent, err = d.lookup(block.LastBlockID)
+			if err != nil {
+				return fmt.Errorf("getting last block of %d: %w", block.SeqID, err)
+			}
+			ok = parent != nil
+		}
+
+		if !ok {
+			r.add(IssueOrphan, block, nil, "last block %s does not exist", block.LastBlockID)
+			continue
+		}
+
+		if block.LastBlockHash != parent.Hash || block.SeqID != parent.SeqID+1 || (!parent.IsGenesis() && block.ChainID != parent.ChainID) {
+			r.add(IssueBrokenLink, block, []uuid.UUID{parent.ID}, "previous block %d [%s] hash [%s]", parent.SeqID, parent.ID, parent.Hash)
+		}
+	}
+
+	d.last = d.last[:0]
+	for _, block := range sorted {
+		if block.SeqID == lastSeqID {
+			d.last = append(d.last, block)
+		}
+	}
+
+	return nil
+}
+
+// Report - the issues found on the received batches
+func (d *ChainDiagnoser) Report() *ChainReport {
+	r := d.report
+	if r.Blocks == 0 {
+		r.Valid = true
+		return r
+	}
+
+	r.Valid = len(r.Issues) == 0
+	return r
+}
+
+// diagnoseBlock - check the block alone, reporting each failure
+func (b *BlockChain) diagnoseBlock(r *ChainReport, block *Block) {
+	if err := CheckHashAlgorithm(blockHashAlgorithm(block)); err != nil {
+		r.add(IssueHash, block, nil, "%s", err.Error())
+		return
+	}
+
+	if err := block.CheckMetadata(); err != nil {
+		kind := IssueMetadata
+		if !errors.Is(err, ErrMetadataTampered) {
+			kind = IssueHash
+		}
+		r.add(kind, block, nil, "%s", err.Error())
+	}
+
+	if err := verifySignature(b.keys, block); err != nil {
+		r.add(IssueSignature, block, nil, "%s", err.Error())
+	}
+
+	if hash := block.CalcHash(); hash != block.Hash {
+		r.add(IssueHash, block, nil, "%s != %s", block.Hash, hash)
+	} else if err := block.CheckHashVersion(); err != nil {
+		r.add(IssueHash, block, nil, "%s", err.Error())
+	}
+}
+
+func blockIDs(blocks []*Block) []uuid.UUID {
+	ids := make([]uuid.UUID, len(blocks))
+	for i, block := range blocks {
+		ids[i] = block.ID
+	}
+	return ids
+}
@@ -0,0 +1,99 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainDiagnose(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < 6; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	report := chain.Diagnose(blocks)
+	assert.True(t, report.Valid)
+	assert.Equal(t, 7, report.Blocks)
+	assert.Empty(t, report.Issues)
+
+	// a second block chained to the block 2
+	parent := blocks[2]
+	fork, err := chain.ChainBlocks(&parent, _mockBlock())
+	assert.Nil(t, err)
+
+	broken := append([]blockchain.Block{}, blocks[:4]...) // gap on 4
+	broken = append(broken, blocks[5:]...)
+	broken = append(broken, *fork)
+	broken[1].Signature = broken[2].Signature
+
+	report = chain.Diagnose(broken)
+	assert.False(t, report.Valid)
+	assert.Equal(t, uint(1), *report.FirstBrokenSeqID)
+	assert.Equal(t, 1, report.Count(blockchain.IssueSignature))
+	assert.Equal(t, 1, report.Count(blockchain.IssueDuplicateSeqID))
+	assert.Equal(t, 1, report.Count(blockchain.IssueFork))
+	assert.Equal(t, 1, report.Count(blockchain.IssueGap))
+	assert.Equal(t, 1, report.Count(blockchain.IssueOrphan))
+
+	for _, issue := range report.Issues {
+		switch issue.Kind {
+		case blockchain.IssueFork:
+			assert.Equal(t, blocks[2].ID, issue.BlockID)
+			assert.ElementsMatch(t, []interface{}{blocks[3].ID, fork.ID}, []interface{}{issue.Related[0], issue.Related[1]})
+		case blockchain.IssueOrphan:
+			assert.Equal(t, blocks[5].ID, issue.BlockID)
+		case blockchain.IssueGap:
+			assert.Equal(t, "missing seqID 4 - 4", issue.Error)
+		}
+	}
+
+	// in batches by seqID, the parents out of the batch are read by the lookup
+	byID := map[uuid.UUID]blockchain.Block{}
+	for _, block := range broken {
+		byID[block.ID] = block
+	}
+	diagnoser := chain.NewDiagnoser(func(id uuid.UUID) (*blockchain.Block, error) {
+		block, ok := byID[id]
+		if !ok {
+			return nil, nil
+		}
+		return &block, nil
+	})
+	batches := [][]blockchain.Block{broken[:2], {broken[2], broken[3], *fork}, broken[4:6]}
+	for _, batch := range batches {
+		err = diagnoser.Diagnose(batch)
+		assert.Nil(t, err)
+	}
+	batched := diagnoser.Report()
+	assert.Equal(t, report.Blocks, batched.Blocks)
+	assert.ElementsMatch(t, _issueKinds(report), _issueKinds(batched))
+
+	broken = append([]blockchain.Block{}, blocks...)
+	broken[3].TransactionStr = `{"system_id":"sauron","table":"admin"}`
+	broken[5].LastBlockHash = broken[3].Hash
+
+	report = chain.Diagnose(broken)
+	assert.Equal(t, 2, report.Count(blockchain.IssueHash))
+	assert.Equal(t, 1, report.Count(blockchain.IssueBrokenLink))
+}
+
+func _issueKinds(report *blockchain.ChainReport) []string {
+	kinds := make([]string, len(report.Issues))
+	for i, issue := range report.Issues {
+		kinds[i] = issue.Kind + " " + issue.BlockID.String()
+	}
+	return kinds
+}
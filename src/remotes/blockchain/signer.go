@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+const (
+	SchemePGP       = "pgp"
+	SchemeEd25519   = "ed25519"
+	SchemeECDSAP256 = "ecdsa-p256"
+)
+
+// Signer - signs the block payload using a private key
+type Signer interface {
+	// Scheme - identifier stored on the block to pick the right verifier
+	Scheme() string
+	// Fingerprint - identifier of the key pair, stored on the signed block
+	Fingerprint() string
+	Sign(message []byte) (string, error)
+	// Verifier - returns the verifier for the public part of the key
+	Verifier() (Verifier, error)
+}
+
+// Verifier - checks a signature produced by a Signer of the same scheme
+type Verifier interface {
+	Scheme() string
+	Fingerprint() string
+	// PublicKey - encoded public key, accepted by NewVerifier
+	PublicKey() string
+	Verify(message []byte, signature string) error
+}
+
+// NewSigner - build a signer given a scheme and the encoded private key
+// pgp uses an armored key and the passphrase, the others use a PKCS8 PEM key
+func NewSigner(scheme, privKey, passphrase string) (Signer, error) {
+	switch scheme {
+	case SchemePGP, "":
+		return NewPGPSigner(privKey, passphrase), nil
+	case SchemeEd25519:
+		return newEd25519SignerFromPEM(privKey)
+	case SchemeECDSAP256:
+		return newECDSASignerFromPEM(privKey)
+	}
+	return nil, fmt.Errorf("unknown signature scheme: %s", scheme)
+}
+
+// NewVerifier - build a verifier given a scheme and the encoded public key
+// pgp uses an armored key, the others use a PKIX PEM key
+func NewVerifier(scheme, pubKey string) (Verifier, error) {
+	switch scheme {
+	case SchemePGP, "":
+		return NewPGPVerifier(pubKey), nil
+	case SchemeEd25519:
+		return newEd25519VerifierFromPEM(pubKey)
+	case SchemeECDSAP256:
+		return newECDSAVerifierFromPEM(pubKey)
+	}
+	return nil, fmt.Errorf("unknown signature scheme: %s", scheme)
+}
+
+// blockScheme - blocks signed before the scheme was recorded are PGP signed
+func blockScheme(block *Block) string {
+	if block.SignatureScheme == "" {
+		return SchemePGP
+	}
+	return block.SignatureScheme
+}
+
+// pkixFingerprint - sha256 of the DER encoded public key
+func pkixFingerprint(pubKey interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(der)
+	return fmt.Sprintf("%x", hash[:])
+}
+
+func encodePKIXPEM(pubKey interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return ""
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func parsePKCS8PEM(privKey string) (interface{}, error) {
+	p, _ := pem.Decode([]byte(privKey))
+	if p == nil {
+		return nil, fmt.Errorf("decoding private key pem")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(p.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	return key, nil
+}
+
+func parsePKIXPEM(pubKey string) (interface{}, error) {
+	p, _ := pem.Decode([]byte(pubKey))
+	if p == nil {
+		return nil, fmt.Errorf("decoding public key pem")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(p.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"logger/utils"
+)
+
+type ecdsaSigner struct {
+	privKey *ecdsa.PrivateKey
+}
+
+type ecdsaVerifier struct {
+	pubKey *ecdsa.PublicKey
+}
+
+// NewECDSASigner - signer using a P-256 private key, signs the sha256 of the message
+func NewECDSASigner(privKey *ecdsa.PrivateKey) Signer {
+	return &ecdsaSigner{privKey: privKey}
+}
+
+// NewECDSAVerifier - verifier using a P-256 public key
+func NewECDSAVerifier(pubKey *ecdsa.PublicKey) Verifier {
+	return &ecdsaVerifier{pubKey: pubKey}
+}
+
+func newECDSASignerFromPEM(privKey string) (Signer, error) {
+	key, err := parsePKCS8PEM(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("private key is not ecdsa p-256")
+	}
+	return NewECDSASigner(ecKey), nil
+}
+
+func newECDSAVerifierFromPEM(pubKey string) (Verifier, error) {
+	key, err := parsePKIXPEM(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("public key is not ecdsa p-256")
+	}
+	return NewECDSAVerifier(ecKey), nil
+}
+
+func (s *ecdsaSigner) Scheme() string {
+	return SchemeECDSAP256
+}
+
+func (s *ecdsaSigner) Fingerprint() string {
+	return pkixFingerprint(&s.privKey.PublicKey)
+}
+
+func (s *ecdsaSigner) Verifier() (Verifier, error) {
+	return NewECDSAVerifier(&s.privKey.PublicKey), nil
+}
+
+func (s *ecdsaSigner) Sign(message []byte) (string, error) {
+	digest := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.privKey, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("generate signature: %w", err)
+	}
+	return utils.ToBase64(sig), nil
+}
+
+func (v *ecdsaVerifier) Scheme() string {
+	return SchemeECDSAP256
+}
+
+func (v *ecdsaVerifier) Fingerprint() string {
+	return pkixFingerprint(v.pubKey)
+}
+
+func (v *ecdsaVerifier) PublicKey() string {
+	return encodePKIXPEM(v.pubKey)
+}
+
+func (v *ecdsaVerifier) Verify(message []byte, signature string) error {
+	sig, err := utils.FromBase64(signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	digest := sha256.Sum256(message)
+	if !ecdsa.VerifyASN1(v.pubKey, digest[:], sig) {
+		return fmt.Errorf("ecdsa signature verification failed")
+	}
+	return nil
+}
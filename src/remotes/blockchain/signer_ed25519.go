@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"logger/utils"
+)
+
+type ed25519Signer struct {
+	privKey ed25519.PrivateKey
+}
+
+type ed25519Verifier struct {
+	pubKey ed25519.PublicKey
+}
+
+// NewEd25519Signer - signer using a raw ed25519 private key
+func NewEd25519Signer(privKey ed25519.PrivateKey) Signer {
+	return &ed25519Signer{privKey: privKey}
+}
+
+// NewEd25519Verifier - verifier using a raw ed25519 public key
+func NewEd25519Verifier(pubKey ed25519.PublicKey) Verifier {
+	return &ed25519Verifier{pubKey: pubKey}
+}
+
+func newEd25519SignerFromPEM(privKey string) (Signer, error) {
+	key, err := parsePKCS8PEM(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not ed25519")
+	}
+	return NewEd25519Signer(edKey), nil
+}
+
+func newEd25519VerifierFromPEM(pubKey string) (Verifier, error) {
+	key, err := parsePKIXPEM(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not ed25519")
+	}
+	return NewEd25519Verifier(edKey), nil
+}
+
+func (s *ed25519Signer) Scheme() string {
+	return SchemeEd25519
+}
+
+func (s *ed25519Signer) Fingerprint() string {
+	return pkixFingerprint(s.privKey.Public())
+}
+
+func (s *ed25519Signer) Verifier() (Verifier, error) {
+	return NewEd25519Verifier(s.privKey.Public().(ed25519.PublicKey)), nil
+}
+
+func (s *ed25519Signer) Sign(message []byte) (string, error) {
+	return utils.ToBase64(ed25519.Sign(s.privKey, message)), nil
+}
+
+func (v *ed25519Verifier) Scheme() string {
+	return SchemeEd25519
+}
+
+func (v *ed25519Verifier) Fingerprint() string {
+	return pkixFingerprint(v.pubKey)
+}
+
+func (v *ed25519Verifier) PublicKey() string {
+	return encodePKIXPEM(v.pubKey)
+}
+
+func (v *ed25519Verifier) Verify(message []byte, signature string) error {
+	sig, err := utils.FromBase64(signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	if !ed25519.Verify(v.pubKey, message, sig) {
+		return fmt.Errorf("ed25519 signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+type pgpSigner struct {
+	privKey    string
+	passphrase string
+}
+
+type pgpVerifier struct {
+	pubKey string
+
+	// the armored key is parsed once, the keyring is only read by the verifications
+	parse   sync.Once
+	keyRing *crypto.KeyRing
+	keyErr  error
+}
+
+// NewPGPSigner - signer using an armored OpenPGP private key locked by a passphrase
+func NewPGPSigner(privKey, passphrase string) Signer {
+	return &pgpSigner{
+		privKey:    privKey,
+		passphrase: passphrase,
+	}
+}
+
+// NewPGPVerifier - verifier using an armored OpenPGP public key
+func NewPGPVerifier(pubKey string) Verifier {
+	return &pgpVerifier{
+		pubKey: pubKey,
+	}
+}
+
+func (s *pgpSigner) Scheme() string {
+	return SchemePGP
+}
+
+// unlockPrivKey - retrieve the privkey given a password
+func (s *pgpSigner) unlockPrivKey() (*crypto.KeyRing, error) {
+	if s.privKey == "" || s.passphrase == "" {
+		return nil, fmt.Errorf("not initialized")
+	}
+
+	passphrase := []byte(s.passphrase) // Private key passphrase
+
+	privateKeyObj, err := crypto.NewKeyFromArmored(s.privKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading privKey: %w", err)
+	}
+	unlockedKey, err := privateKeyObj.Unlock(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("opening privkey with passphrase: %w", err)
+	}
+
+	signingKeyRing, err := crypto.NewKeyRing(unlockedKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating keyring")
+	}
+	return signingKeyRing, nil
+}
+
+func (s *pgpSigner) Fingerprint() string {
+	return pgpFingerprint(s.privKey)
+}
+
+func (s *pgpSigner) Verifier() (Verifier, error) {
+	privateKeyObj, err := crypto.NewKeyFromArmored(s.privKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading privKey: %w", err)
+	}
+
+	pubKey, err := privateKeyObj.GetArmoredPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("extracting pubKey: %w", err)
+	}
+	return NewPGPVerifier(pubKey), nil
+}
+
+func (s *pgpSigner) Sign(message []byte) (string, error) {
+	privKey, err := s.unlockPrivKey()
+	if err != nil {
+		return "", fmt.Errorf("unlocking privKey: %w", err)
+	}
+
+	pgpSignature, err := privKey.SignDetached(crypto.NewPlainMessage(message))
+	if err != nil {
+		return "", fmt.Errorf("generate signature: %w", err)
+	}
+
+	return formatSignatureToPGP(pgpSignature.Data), nil
+}
+
+func (v *pgpVerifier) Scheme() string {
+	return SchemePGP
+}
+
+// getPubKey - return a instance of pubkey from the pubkey string, parsed on the first call
+func (v *pgpVerifier) getPubKey() (*crypto.KeyRing, error) {
+	v.parse.Do(func() {
+		v.keyRing, v.keyErr = parsePGPPubKey(v.pubKey)
+	})
+	return v.keyRing, v.keyErr
+}
+
+func parsePGPPubKey(pubKey string) (*crypto.KeyRing, error) {
+	publicKeyObj, err := crypto.NewKeyFromArmored(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading pubKey: %w", err)
+	}
+	signingKeyRing, err := crypto.NewKeyRing(publicKeyObj)
+	if err != nil {
+		return nil, fmt.Errorf("generating keyring: %w", err)
+	}
+	return signingKeyRing, nil
+}
+
+func (v *pgpVerifier) Fingerprint() string {
+	return pgpFingerprint(v.pubKey)
+}
+
+func (v *pgpVerifier) PublicKey() string {
+	return v.pubKey
+}
+
+func (v *pgpVerifier) Verify(message []byte, signature string) error {
+	pubKey, err := v.getPubKey()
+	if err != nil {
+		return fmt.Errorf("getting pubkey: %w", err)
+	}
+
+	pgpSignature, err := crypto.NewPGPSignatureFromArmored(signature)
+	if err != nil {
+		return fmt.Errorf("opening signature: %w", err)
+	}
+
+	return pubKey.VerifyDetached(crypto.NewPlainMessage(message), pgpSignature, crypto.GetUnixTime())
+}
+
+// pgpFingerprint - fingerprint of an armored key, empty when the key is unreadable
+func pgpFingerprint(armored string) string {
+	key, err := crypto.NewKeyFromArmored(armored)
+	if err != nil {
+		return ""
+	}
+	return key.GetFingerprint()
+}
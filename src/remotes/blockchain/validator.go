@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ChainValidator - validate a chain received in batches ordered by seqID
+// only the last verified block and the key state are kept between the batches, so the links across them are still checked
+type ChainValidator struct {
+	chain     *BlockChain
+	lastBlock Block
+	started   bool
+
+	// key expected to sign the blocks, switched on each rotation block
+	// empty before the first rotation, when any configured key is accepted
+	activeKey string
+	// sub-chains have no rotation blocks, their keys can only move forward on the rotations
+	generation int
+
+	// Validated blocks count
+	Validated int
+}
+
+// NewValidator - validator starting on the first block received, the genesis or any other block
+// when it does not start on genesis the first block is only checked by itself
+func (b *BlockChain) NewValidator() *ChainValidator {
+	return &ChainValidator{chain: b}
+}
+
+// NewValidatorFrom - validator linking the first block received to an already verified block
+func (b *BlockChain) NewValidatorFrom(lastBlock Block) *ChainValidator {
+	v := &ChainValidator{chain: b, lastBlock: lastBlock, started: true}
+	if lastBlock.ChainID != "" {
+		v.generation = b.keys.generation(lastBlock.KeyFingerprint)
+	}
+	return v
+}
+
+// LastBlock - last verified block
+func (v *ChainValidator) LastBlock() Block {
+	return v.lastBlock
+}
+
+// Validate - validate the next batch, linked to the last block of the previous one
+func (v *ChainValidator) Validate(blocks []Block) error {
+	if !v.chain.Checkable() {
+		return fmt.Errorf("not initiliazed")
+	}
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	b := v.chain
+
+	if !v.started {
+		v.started = true
+		v.lastBlock = blocks[0]
+		v.activeKey = b.keys.activeAt(blocks[0].SeqID)
+
+		if blocks[0].ID.String() != GENESIS_ID_BLOCK {
+			err := b.validateBlock(&blocks[0])
+			if err != nil {
+				return fmt.Errorf("validating first block %d: %w", blocks[0].SeqID, err)
+			}
+			v.Validated++
+			blocks = blocks[1:]
+		}
+	} else if v.Validated == 0 {
+		v.activeKey = b.keys.activeAt(blocks[0].SeqID)
+	}
+
+	verified := b.verifyBlocks(blocks)
+
+	for i := range blocks {
+		block := blocks[i]
+
+		// a failed block is verified again in order, its key can be announced by a rotation of this batch
+		err := verified[i]
+		if err != nil {
+			err = b.validateBlock(&block)
+		}
+		if err != nil {
+			return fmt.Errorf("validating block: %w", err)
+		}
+
+		if block.ChainID != "" {
+			// the key can not go back on the sub-chain nor be older than the global key when the block was signed
+			keyGeneration := b.keys.generation(block.KeyFingerprint)
+			if keyGeneration < v.generation || keyGeneration < b.keys.generationAt(block.signingTime()) {
+				return fmt.Errorf("block signed by a retired key! blockID: %s seqBlock: %d chain: %s key: [%s]",
+					block.ID.String(),
+					block.SeqID,
+					block.ChainID,
+					block.KeyFingerprint)
+			}
+			v.generation = keyGeneration
+		} else {
+			// before the first rotation any configured key signs, so the scheme can be switched by config
+			// keys announced by rotation blocks only sign after their rotation
+			if v.activeKey == "" && b.keys.generation(block.KeyFingerprint) > 0 {
+				return fmt.Errorf("block signed by a key before its rotation! blockID: %s seqBlock: %d key: [%s]",
+					block.ID.String(),
+					block.SeqID,
+					block.KeyFingerprint)
+			}
+
+			if v.activeKey != "" && block.KeyFingerprint != v.activeKey {
+				return fmt.Errorf("block not signed by the active key! blockID: %s seqBlock: %d key: [%s] activeKey: [%s]",
+					block.ID.String(),
+					block.SeqID,
+					block.KeyFingerprint,
+					v.activeKey)
+			}
+
+			if block.IsKeyRotation() {
+				v.activeKey, err = b.learnRotation(&block)
+				if err != nil {
+					return fmt.Errorf("rotating key on block %d: %w", block.SeqID, err)
+				}
+			}
+		}
+
+		v.Validated++
+
+		if block.IsGenesis() {
+			continue
+		}
+
+		lastBlock := v.lastBlock
+		if !lastBlock.IsGenesis() && block.ChainID != lastBlock.ChainID {
+			return fmt.Errorf("chain is broken, block from another chain! blockID: %s seqBlock: %d chain: [%s] lastChain: [%s]",
+				block.ID.String(),
+				block.SeqID,
+				block.ChainID,
+				lastBlock.ChainID)
+		}
+
+		if block.LastBlockHash != lastBlock.Hash {
+			return fmt.Errorf("chain is broken, last block is different! blockID: %s seqBlock: %d Comparation: [%s] [%s] LastBLockId: [%s]",
+				block.ID.String(),
+				block.SeqID,
+				block.LastBlockHash,
+				lastBlock.Hash,
+				lastBlock.ID.String())
+		}
+
+		v.lastBlock = block
+	}
+
+	return nil
+}
+
+// verifyBlocks - verify the signature and hash of each block on the workers, returns the errors by block position
+func (b *BlockChain) verifyBlocks(blocks []Block) []error {
+	errs := make([]error, len(blocks))
+
+	workers := b.validationWorkers()
+	if workers > len(blocks) {
+		workers = len(blocks)
+	}
+
+	if workers <= 1 {
+		for i := range blocks {
+			errs[i] = b.validateBlock(&blocks[i])
+		}
+		return errs
+	}
+
+	positions := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range positions {
+				errs[i] = b.validateBlock(&blocks[i])
+			}
+		}()
+	}
+
+	for i := range blocks {
+		positions <- i
+	}
+	close(positions)
+	wg.Wait()
+
+	return errs
+}
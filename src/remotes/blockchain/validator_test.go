@@ -0,0 +1,109 @@
+package blockchain_test
+
+import (
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func _validateInBatches(chain *blockchain.BlockChain, blocks []blockchain.Block, size int) (*blockchain.ChainValidator, error) {
+	validator := chain.NewValidator()
+	for i := 0; i < len(blocks); i += size {
+		end := i + size
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+
+		err := validator.Validate(blocks[i:end])
+		if err != nil {
+			return validator, err
+		}
+	}
+	return validator, nil
+}
+
+func TestChainValidatorBatches(t *testing.T) {
+	signer, verifier := _generateMockEd25519()
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(signer)
+	chain.AddVerifier(verifier)
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	for _, size := range []int{1, 3, 4, 11, 50} {
+		validator, err := _validateInBatches(chain, blocks, size)
+		assert.Nil(t, err)
+		assert.Equal(t, 11, validator.Validated)
+		assert.Equal(t, blocks[10].ID, validator.LastBlock().ID)
+	}
+
+	// starting out of the genesis
+	validator, err := _validateInBatches(chain, blocks[4:], 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, validator.Validated)
+
+	// a valid block chained to the block 1 on the first block of the second batch
+	parent := blocks[1]
+	fork, err := chain.ChainBlocks(&parent, _mockBlock())
+	assert.Nil(t, err)
+
+	broken := append([]blockchain.Block{}, blocks...)
+	broken[3] = *fork
+
+	validator, err = _validateInBatches(chain, broken, 3)
+	assert.ErrorContains(t, err, "chain is broken, last block is different")
+	assert.Equal(t, blocks[2].ID, validator.LastBlock().ID)
+}
+
+func TestChainValidatorWorkers(t *testing.T) {
+	pass := "very very long long key"
+	privKey, pubKey, err := _generateMockKey(pass)
+	assert.Nil(t, err)
+
+	chain := blockchain.NewBlockChain(pubKey)
+	chain.SetAuth(privKey, pass)
+
+	err = chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	next, _ := _generateMockEd25519()
+	_, err = chain.RotateKey(next)
+	assert.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = chain.AppendBlock(_mockBlock())
+		assert.Nil(t, err)
+	}
+
+	blocks := append([]blockchain.Block{}, chain.Chain...)
+
+	// an auditor knowing only the root key learns the next one on the middle of the batch
+	auditor := blockchain.NewBlockChain(pubKey)
+	auditor.SetValidationWorkers(4)
+
+	validator, err := _validateInBatches(auditor, blocks, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, len(blocks), validator.Validated)
+
+	tampered := append([]blockchain.Block{}, blocks...)
+	tampered[7].Tags = "forged"
+	err = tampered[7].HashBlock()
+	assert.Nil(t, err)
+
+	_, err = _validateInBatches(auditor, tampered, 20)
+	assert.ErrorContains(t, err, "signature verification failed")
+}
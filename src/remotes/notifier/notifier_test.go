@@ -0,0 +1,46 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"logger/remotes/notifier"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifiers(t *testing.T) {
+	var received map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received = map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	alert := notifier.Alert{
+		Service: "logger",
+		Title:   "chain validation failed",
+		Error:   "chain is broken",
+		At:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	err := notifier.NewWebhook(server.URL).Notify(context.Background(), alert)
+	assert.Nil(t, err)
+	assert.Equal(t, "chain is broken", received["error"])
+	assert.Equal(t, "2024-01-02T03:04:05Z", received["at"])
+
+	err = notifier.NewSlack(server.URL, "logger-stag").Notify(context.Background(), alert)
+	assert.Nil(t, err)
+	assert.Equal(t, "logger-stag", received["channel"])
+	assert.Len(t, received["attachments"], 1)
+
+	status = http.StatusInternalServerError
+	err = notifier.NewWebhook(server.URL).Notify(context.Background(), alert)
+	assert.ErrorContains(t, err, "unexpected status 500")
+}
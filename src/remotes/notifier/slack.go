@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type slack struct {
+	webhook string
+	channel string
+}
+
+// NewSlack - notifier posting on a slack incoming webhook, the channel is optional
+func NewSlack(webhook, channel string) Notifier {
+	return &slack{
+		webhook: webhook,
+		channel: channel,
+	}
+}
+
+func (s *slack) Name() string {
+	return "slack"
+}
+
+func (s *slack) Notify(ctx context.Context, alert Alert) error {
+	payload := map[string]interface{}{
+		"username": alert.Service,
+		"attachments": []map[string]string{{
+			"title": fmt.Sprintf(":exclamation: [%s] %s", alert.Service, alert.Title),
+			"text":  fmt.Sprintf("*Timestamp:* %s \n*Error:* %s", alert.At.UTC().Format(time.RFC3339), alert.Error),
+			"color": "#DF3A01",
+		}},
+	}
+	if s.channel != "" {
+		payload["channel"] = s.channel
+	}
+
+	err := postJSON(ctx, s.webhook, payload)
+	if err != nil {
+		return fmt.Errorf("notifying slack: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+)
+
+type webhook struct {
+	url string
+}
+
+// NewWebhook - notifier posting the alert as json on the url
+func NewWebhook(url string) Notifier {
+	return &webhook{
+		url: url,
+	}
+}
+
+func (w *webhook) Name() string {
+	return "webhook"
+}
+
+func (w *webhook) Notify(ctx context.Context, alert Alert) error {
+	err := postJSON(ctx, w.url, alert)
+	if err != nil {
+		return fmt.Errorf("notifying webhook: %w", err)
+	}
+
+	return nil
+}
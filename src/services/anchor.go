@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joaopandolfi/blackwhale/cron"
+	"github.com/joaopandolfi/blackwhale/utils"
+)
+
+// anchorJob - cron job recording the sub-chain heads on the global chain
+type anchorJob struct {
+	service BlockChain
+}
+
+func NewAnchorJob(service BlockChain) cron.Job {
+	return &anchorJob{
+		service: service,
+	}
+}
+
+func (j *anchorJob) Trigger() bool {
+	return true
+}
+
+func (j *anchorJob) Eval() error {
+	block, err := j.service.Anchor(context.Background())
+	if err != nil {
+		return fmt.Errorf("anchoring sub-chains: %w", err)
+	}
+
+	if block != nil {
+		utils.Info("[BlockChain] sub-chains anchored", block.SeqID)
+	}
+
+	return nil
+}
+
+func (j *anchorJob) Stop() error {
+	return nil
+}
@@ -2,63 +2,792 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"logger/config"
+	"logger/models"
 	"logger/models/dao"
 	"logger/remotes/blockchain"
+	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
+	"github.com/joaopandolfi/blackwhale/utils"
 )
 
 type BlockChain interface {
-	Validate(ctx context.Context) error
-	ValidateSegment(ctx context.Context, init, end int) error
+	Validate(ctx context.Context, full bool) error
+	ValidateChain(ctx context.Context, chainID string, full bool) error
+	ValidateAndCheckpoint(ctx context.Context) error
+	LastValidation(ctx context.Context) (*models.ValidationResult, error)
+	ValidateSegment(ctx context.Context, chainID string, init, end int) error
+	Checkpoints(ctx context.Context, chainID string) ([]blockchain.Checkpoint, error)
+	VerifyCheckpoint(ctx context.Context, id uuid.UUID) (*blockchain.Checkpoint, error)
+	Diagnose(ctx context.Context, chainID string) (*blockchain.ChainReport, error)
+	LoadKeys(ctx context.Context) error
+	RotateKey(ctx context.Context, next blockchain.Signer) (*blockchain.Block, error)
+	Anchor(ctx context.Context) (*blockchain.Block, error)
+	Head(ctx context.Context, chainID string) (*models.ChainHead, error)
+	ListBlocks(ctx context.Context, systemID string, afterSeq *uint, limit int) (*models.BlockPage, error)
+	Export(ctx context.Context, chainID string, init, end uint, w io.Writer) error
+	Import(ctx context.Context, r io.Reader) (*blockchain.ArchiveManifest, int, error)
 }
 
 type blockChainService struct {
-	dao dao.BlockChain
+	dao   dao.BlockChain
+	chain *blockchain.BlockChain
+	// blocks read by batch on the validations
+	batchSize int
+	// blocks the head must advance to sign a new checkpoint
+	checkpointBlocks int
+	// each system has its own chain
+	subChains bool
 }
 
-func NewBlockChain() BlockChain {
+func NewBlockChain(chain *blockchain.BlockChain) BlockChain {
+	validation := config.Get().Validation
 	return &blockChainService{
-		dao: dao.NewBlockChainDao(),
+		dao:              dao.NewBlockChainDao(chain),
+		chain:            chain,
+		batchSize:        validation.BatchSize,
+		checkpointBlocks: validation.CheckpointBlocks,
+		subChains:        config.Get().BlockChain.SubChains,
 	}
 }
 
-func (s *blockChainService) Validate(ctx context.Context) error {
-	sCtx, tracer := jaeger.SpanTrace(ctx, "service.Validate", nil)
+// Validate - validate the global chain and every sub-chain
+// full ignores the checkpoints, verifying every chain from the genesis
+func (s *blockChainService) Validate(ctx context.Context, full bool) error {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.Validate", map[string]interface{}{"full": full})
 	defer tracer.Finish()
 
-	return s.ValidateSegment(sCtx, 0, 0)
+	return s.eachChain(func(chainID string) error {
+		return s.ValidateChain(sCtx, chainID, full)
+	})
 }
 
-func (s *blockChainService) ValidateSegment(ctx context.Context, init, end int) error {
-	_, tracer := jaeger.SpanTrace(ctx, "service.ValidateSegment", map[string]interface{}{"init": init, "end": end})
+// ValidateChain - validate the blocks after the last checkpoint of the chain
+// full ignores the checkpoints, verifying the chain from the genesis
+func (s *blockChainService) ValidateChain(ctx context.Context, chainID string, full bool) error {
+	_, err := s.verifyChain(ctx, chainID, full)
+	return err
+}
+
+// ErrValidationRunning - the background validation is running on another replica
+var ErrValidationRunning = errors.New("validation running on another replica")
+
+// ValidateAndCheckpoint - validate every chain after its last checkpoint and sign a checkpoint on the heads
+// advanced at least VALIDATION_CHECKPOINT_BLOCKS blocks, used by the background validation
+// only one replica runs it at a time, the others get ErrValidationRunning
+func (s *blockChainService) ValidateAndCheckpoint(ctx context.Context) error {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.ValidateAndCheckpoint", nil)
+	defer tracer.Finish()
+
+	var err error
+	ran, lockErr := s.dao.RunExclusive(sCtx, validationJobName, func() error {
+		err = s.recordValidation(sCtx, func() error {
+			return s.validateAndCheckpoint(sCtx)
+		})
+		return nil
+	})
+	if lockErr != nil {
+		return fmt.Errorf("locking validation: %w", lockErr)
+	}
+	if !ran {
+		return ErrValidationRunning
+	}
+
+	return err
+}
+
+// validationJobName - job of the background validation, locked between the replicas and storing its result
+const validationJobName = "validation"
+
+// recordValidation - store the validation as running while validate runs and then its result
+// the result is read by every replica, failing to store it does not fail the validation
+func (s *blockChainService) recordValidation(ctx context.Context, validate func() error) error {
+	replica, _ := os.Hostname()
+	result := &models.ValidationResult{
+		Job:       validationJobName,
+		Status:    models.ValidationRunning,
+		Replica:   replica,
+		StartedAt: time.Now().UTC(),
+	}
+
+	err := s.dao.SaveValidation(ctx, result)
+	if err != nil {
+		utils.Error("[Validation] saving running validation", err.Error())
+	}
+
+	err = validate()
+
+	result.Status = models.ValidationValid
+	result.Valid = err == nil
+	result.Duration = time.Since(result.StartedAt).String()
+	if err != nil {
+		result.Status = models.ValidationInvalid
+		result.Error = err.Error()
+	}
+
+	saveErr := s.dao.SaveValidation(ctx, result)
+	if saveErr != nil {
+		utils.Error("[Validation] saving validation result", saveErr.Error())
+	}
+
+	return err
+}
+
+// LastValidation - last background validation of any replica, running while a replica validates, nil before the first one
+func (s *blockChainService) LastValidation(ctx context.Context) (*models.ValidationResult, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.LastValidation", nil)
+	defer tracer.Finish()
+
+	result, err := s.dao.GetValidation(validationJobName)
+	if errors.Is(err, dao.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting last validation: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *blockChainService) validateAndCheckpoint(sCtx context.Context) error {
+	chain := s.chain
+
+	return s.eachChain(func(chainID string) error {
+		validator, err := s.verifyChain(sCtx, chainID, false)
+		if err != nil {
+			return err
+		}
+
+		if validator.Validated < s.checkpointBlocks || !chain.HaveAuth() {
+			return nil
+		}
+
+		last := validator.LastBlock()
+		next, err := chain.NewCheckpoint(&last, validator.Validated)
+		if err != nil {
+			return fmt.Errorf("signing checkpoint: %w", err)
+		}
+
+		err = s.dao.SaveCheckpoint(sCtx, next)
+		if err != nil {
+			return fmt.Errorf("saving checkpoint: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// eachChain - run on the global chain and on every sub-chain, stopping on the first error
+func (s *blockChainService) eachChain(fn func(chainID string) error) error {
+	err := fn("")
+	if err != nil {
+		return err
+	}
+
+	chainIDs, err := s.dao.GetChainIDs()
+	if err != nil {
+		return fmt.Errorf("getting sub-chains: %w", err)
+	}
+
+	for _, chainID := range chainIDs {
+		err = fn(chainID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyChain - validate the blocks after the last trusted checkpoint of the chain, or from the genesis when full
+func (s *blockChainService) verifyChain(ctx context.Context, chainID string, full bool) (*blockchain.ChainValidator, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.ValidateChain", map[string]interface{}{"chain": chainID, "full": full})
 	defer tracer.Finish()
 
-	blocks, err := s.dao.GetSegment(init, end)
+	err := s.LoadKeys(sCtx)
+	if err != nil {
+		return nil, fmt.Errorf("loading keys: %w", err)
+	}
+
+	var checkpoint *blockchain.Checkpoint
+	if !full {
+		checkpoint, err = s.dao.GetLastCheckpoint(chainID)
+		if err != nil && !errors.Is(err, dao.ErrNotFound) {
+			return nil, fmt.Errorf("getting last checkpoint: %w", err)
+		}
+	}
+
+	return s.validateChain(sCtx, chainID, checkpoint, s.batchSize)
+}
+
+// ValidateSegment - validate the blocks [init, end] of a chain, the empty chainID is the global chain
+// sub-chains are also checked against the heads anchored on the global chain
+func (s *blockChainService) ValidateSegment(ctx context.Context, chainID string, init, end int) error {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.ValidateSegment", map[string]interface{}{"chain": chainID, "init": init, "end": end})
+	defer tracer.Finish()
+
+	if init == 0 && end == 0 {
+		return s.ValidateChain(sCtx, chainID, false)
+	}
+
+	err := s.LoadKeys(sCtx)
+	if err != nil {
+		return fmt.Errorf("loading keys: %w", err)
+	}
+
+	blocks, err := s.dao.GetSegment(chainID, init, end)
 	if err != nil {
 		return fmt.Errorf("getting blocks (%d, %d): %w", init, end, err)
 	}
 
-	chainSize := len(blocks)
-	if chainSize == 0 {
+	if len(blocks) == 0 {
 		return nil
 	}
 
-	chain := blockchain.Get()
-	chain.Chain = blocks
-	defer chain.Clean()
+	// sub-chains start linked to the genesis
+	if chainID != "" && blocks[0].SeqID == 1 {
+		genesis, err := s.genesis()
+		if err != nil {
+			return err
+		}
+		blocks = append([]blockchain.Block{*genesis}, blocks...)
+	}
+
+	chain := s.chain
+	err = chain.ValidateBlocks(blocks)
+	if err != nil {
+		return fmt.Errorf("validating %d blocks [%d - %d] on chain %s: %w", len(blocks), init, end, chainID, err)
+	}
+
+	if chainID == "" {
+		return nil
+	}
+
+	anchors, err := s.dao.GetAnchors()
+	if err != nil {
+		return fmt.Errorf("getting anchors: %w", err)
+	}
+
+	err = chain.CheckAnchors(chainID, blocks, anchors, end == 0)
+	if err != nil {
+		return fmt.Errorf("checking anchors of chain %s: %w", chainID, err)
+	}
+
+	return nil
+}
+
+// validateChain - validate the chain after the checkpoint, or the whole chain without it, reading it in batches by seqID
+// the last verified block is carried to the next batch, so only one batch is kept on memory
+func (s *blockChainService) validateChain(ctx context.Context, chainID string, checkpoint *blockchain.Checkpoint, batchSize int) (*blockchain.ChainValidator, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.validateChain", map[string]interface{}{"chain": chainID, "batch": batchSize})
+	defer tracer.Finish()
+
+	chain := s.chain
+	validator := chain.NewValidator()
+
+	// the anchors are verified once, each batch is checked against their heads
+	var anchors *blockchain.AnchorChecker
+	if chainID != "" {
+		anchorBlocks, err := s.dao.GetAnchors()
+		if err != nil {
+			return nil, fmt.Errorf("getting anchors: %w", err)
+		}
+
+		anchors, err = chain.NewAnchorChecker(chainID, anchorBlocks)
+		if err != nil {
+			return nil, fmt.Errorf("checking anchors of chain %s: %w", chainID, err)
+		}
+	}
+
+	from := uint(0)
+	var checkpointBlocks []blockchain.Block
+	// the checkpoints of a retired key are not trusted, the chain is verified from its start
+	if checkpoint != nil {
+		err := chain.VerifyCheckpoint(checkpoint)
+		if errors.Is(err, blockchain.ErrCheckpointKeyRetired) {
+			checkpoint = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("verifying checkpoint: %w", err)
+		}
+	}
+
+	if checkpoint != nil {
+
+		block, err := s.dao.GetBlock(checkpoint.BlockID)
+		if err != nil {
+			return nil, fmt.Errorf("getting checkpoint %s block: %w", checkpoint.ID, err)
+		}
+
+		err = checkpoint.Matches(block)
+		if err != nil {
+			return nil, fmt.Errorf("checking checkpoint: %w", err)
+		}
+
+		validator = chain.NewValidatorFrom(*block)
+		checkpointBlocks = []blockchain.Block{*block}
+		from = block.SeqID + 1
+	}
+
+	blocks, err := s.dao.GetBlocksPage(chainID, from, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks from %d: %w", from, err)
+	}
+
+	// without a checkpoint the chain is verified from its start, a missing prefix can not be skipped
+	if checkpoint == nil && len(blocks) > 0 {
+		err = checkChainStart(chainID, &blocks[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// sub-chains start linked to the genesis
+	if chainID != "" && checkpoint == nil && len(blocks) > 0 {
+		genesis, err := s.genesis()
+		if err != nil {
+			return nil, err
+		}
+
+		err = validator.Validate([]blockchain.Block{*genesis})
+		if err != nil {
+			return nil, fmt.Errorf("validating genesis: %w", err)
+		}
+	}
+
+	for len(blocks) > 0 {
+		err = validator.Validate(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("validating blocks [%d - %d] on chain %s: %w", blocks[0].SeqID, blocks[len(blocks)-1].SeqID, chainID, err)
+		}
+
+		next, err := s.dao.GetBlocksPage(chainID, validator.LastBlock().SeqID+1, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("getting blocks after %d: %w", validator.LastBlock().SeqID, err)
+		}
+
+		if anchors != nil {
+			err = anchors.Check(blocks, len(next) == 0)
+			if err != nil {
+				return nil, fmt.Errorf("checking anchors of chain %s: %w", chainID, err)
+			}
+		}
+
+		blocks = next
+	}
+
+	// no new blocks, the anchored heads can not be after the checkpoint
+	if anchors != nil && validator.Validated == 0 {
+		err = anchors.Check(checkpointBlocks, true)
+		if err != nil {
+			return nil, fmt.Errorf("checking anchors of chain %s: %w", chainID, err)
+		}
+	}
+
+	return validator, nil
+}
+
+// Checkpoints - validation checkpoints of the chain, the newest first
+func (s *blockChainService) Checkpoints(ctx context.Context, chainID string) ([]blockchain.Checkpoint, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Checkpoints", map[string]interface{}{"chain": chainID})
+	defer tracer.Finish()
+
+	checkpoints, err := s.dao.GetCheckpoints(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("getting checkpoints: %w", err)
+	}
+
+	return checkpoints, nil
+}
+
+// VerifyCheckpoint - check the checkpoint signature and that its block was not rewritten
+func (s *blockChainService) VerifyCheckpoint(ctx context.Context, id uuid.UUID) (*blockchain.Checkpoint, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.VerifyCheckpoint", map[string]interface{}{"id": id})
+	defer tracer.Finish()
+
+	checkpoint, err := s.dao.GetCheckpoint(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting checkpoint: %w", err)
+	}
+
+	err = s.LoadKeys(sCtx)
+	if err != nil {
+		return nil, fmt.Errorf("loading keys: %w", err)
+	}
+
+	err = s.chain.VerifyCheckpoint(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := s.dao.GetBlock(checkpoint.BlockID)
+	if errors.Is(err, dao.ErrNotFound) {
+		return nil, fmt.Errorf("checkpoint block %s does not exist", checkpoint.BlockID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting checkpoint block: %w", err)
+	}
+
+	err = checkpoint.Matches(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+// Diagnose - walk the whole chain reporting every fork, gap, broken link and invalid block
+func (s *blockChainService) Diagnose(ctx context.Context, chainID string) (*blockchain.ChainReport, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.Diagnose", map[string]interface{}{"chain": chainID})
+	defer tracer.Finish()
+
+	err := s.LoadKeys(sCtx)
+	if err != nil {
+		return nil, fmt.Errorf("loading keys: %w", err)
+	}
+
+	diagnoser := s.chain.NewDiagnoser(s.lookupBlock)
+
+	// sub-chains start linked to the genesis
+	if chainID != "" {
+		genesis, err := s.genesis()
+		if err != nil {
+			return nil, err
+		}
+
+		err = diagnoser.Diagnose([]blockchain.Block{*genesis})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	batchSize := s.batchSize
+	from := uint(0)
+	for {
+		blocks, err := s.dao.GetBlocksPage(chainID, from, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("getting blocks from %d: %w", from, err)
+		}
+
+		if len(blocks) == 0 {
+			break
+		}
+
+		// the blocks of a seqID are diagnosed together, a full page can cut the duplicates of the last one
+		// (the range of seqID 0 would go until the head, the genesis is not read again)
+		lastSeqID := blocks[len(blocks)-1].SeqID
+		if len(blocks) == batchSize && lastSeqID > 0 {
+			last, err := s.dao.GetBlocksBetween(chainID, lastSeqID, lastSeqID)
+			if err != nil {
+				return nil, fmt.Errorf("getting blocks of %d: %w", lastSeqID, err)
+			}
+
+			for len(blocks) > 0 && blocks[len(blocks)-1].SeqID == lastSeqID {
+				blocks = blocks[:len(blocks)-1]
+			}
+			blocks = append(blocks, last...)
+		}
+
+		err = diagnoser.Diagnose(blocks)
+		if err != nil {
+			return nil, err
+		}
+
+		from = lastSeqID + 1
+	}
+
+	report := diagnoser.Report()
+	report.ChainID = chainID
+
+	return report, nil
+}
+
+// checkChainStart - the first stored block of a chain must be the genesis, or the block 1 of a sub-chain
+func checkChainStart(chainID string, first *blockchain.Block) error {
+	if chainID == "" && !first.IsGenesis() {
+		return fmt.Errorf("chain starts on block %d, the genesis is missing", first.SeqID)
+	}
+
+	if chainID != "" && first.SeqID != 1 {
+		return fmt.Errorf("chain %s starts on block %d, the blocks before it are missing", chainID, first.SeqID)
+	}
+
+	return nil
+}
+
+// genesis - the genesis block, sub-chains start linked to it
+func (s *blockChainService) genesis() (*blockchain.Block, error) {
+	genesisID, _ := uuid.Parse(blockchain.GENESIS_ID_BLOCK)
+	genesis, err := s.dao.GetBlock(genesisID)
+	if err != nil {
+		return nil, fmt.Errorf("getting genesis: %w", err)
+	}
+	return genesis, nil
+}
 
-	if chain.GenesisBlock.ID == uuid.Nil {
-		chain.GenesisBlock = chain.Chain[0]
-		chain.Chain = chain.Chain[1 : chainSize-1]
+// lookupBlock - block by id, nil when it does not exist
+func (s *blockChainService) lookupBlock(id uuid.UUID) (*blockchain.Block, error) {
+	block, err := s.dao.GetBlock(id)
+	if errors.Is(err, dao.ErrNotFound) {
+		return nil, nil
 	}
+	return block, err
+}
+
+// Anchor - record on the global chain the sub-chain heads changed since the last anchor
+// returns nil when no head changed
+func (s *blockChainService) Anchor(ctx context.Context) (*blockchain.Block, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.Anchor", nil)
+	defer tracer.Finish()
+
+	heads, err := s.dao.GetChainHeads()
+	if err != nil {
+		return nil, fmt.Errorf("getting heads: %w", err)
+	}
+
+	anchored := map[string]blockchain.AnchorHead{}
+	lastAnchor, err := s.dao.GetLastAnchor()
+	if err != nil && !errors.Is(err, dao.ErrNotFound) {
+		return nil, fmt.Errorf("getting last anchor: %w", err)
+	}
+	if lastAnchor != nil {
+		anchored, err = lastAnchor.Anchors()
+		if err != nil {
+			return nil, fmt.Errorf("reading last anchor: %w", err)
+		}
+	}
+
+	changed := []blockchain.Block{}
+	for _, head := range heads {
+		if anchor, ok := anchored[head.ChainID]; ok && anchor.ID == head.ID {
+			continue
+		}
+		changed = append(changed, head)
+	}
+
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	anchorBlock, err := s.dao.AppendBlock(sCtx, blockchain.NewAnchorBlock(changed))
+	if err != nil {
+		return nil, fmt.Errorf("appending anchor block: %w", err)
+	}
+
+	return anchorBlock, nil
+}
+
+// LoadKeys - trust the keys announced by the rotation blocks stored on database
+func (s *blockChainService) LoadKeys(ctx context.Context) error {
+	_, tracer := jaeger.SpanTrace(ctx, "service.LoadKeys", nil)
+	defer tracer.Finish()
 
-	err = chain.Validate()
+	rotations, err := s.dao.GetKeyRotations()
 	if err != nil {
-		return fmt.Errorf("validating %d blocks [%d - %d] on chain: %w", init, end, len(chain.Chain), err)
+		return fmt.Errorf("getting rotations: %w", err)
+	}
+
+	err = s.chain.LearnRotations(rotations)
+	if err != nil {
+		return fmt.Errorf("learning rotations: %w", err)
 	}
 
 	return nil
 }
+
+// RotateKey - chain a block announcing the next key, signed by the current one
+// if the next key was already announced the chain just starts signing with it
+func (s *blockChainService) RotateKey(ctx context.Context, next blockchain.Signer) (*blockchain.Block, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.RotateKey", map[string]interface{}{"next": next.Fingerprint()})
+	defer tracer.Finish()
+
+	chain := s.chain
+	if chain.Rotated(next.Fingerprint()) {
+		chain.SetSigner(next)
+		return nil, nil
+	}
+
+	verifier, err := next.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("getting next key verifier: %w", err)
+	}
+
+	rotationBlock, err := s.dao.AppendBlock(sCtx, blockchain.NewKeyRotationBlock(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("appending rotation block: %w", err)
+	}
+
+	err = chain.ActivateRotation(rotationBlock, next)
+	if err != nil {
+		return nil, fmt.Errorf("activating next key: %w", err)
+	}
+
+	return rotationBlock, nil
+}
+
+// Export - write the blocks of the chain with seqID in [init, end] and their entries as an archive, end 0 goes until the head
+// the blocks are read and written by pages of the validation batch size, so only a page is kept on memory
+func (s *blockChainService) Export(ctx context.Context, chainID string, init, end uint, w io.Writer) error {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Export", map[string]interface{}{"chain": chainID, "init": init, "end": end})
+	defer tracer.Finish()
+
+	archive := blockchain.NewArchiveWriter(w, s.chain.PublicKeys())
+
+	from := init
+	for {
+		blocks, err := s.dao.GetBlocksPage(chainID, from, s.batchSize)
+		if err != nil {
+			return fmt.Errorf("getting blocks from %d: %w", from, err)
+		}
+
+		full := len(blocks) == s.batchSize
+		for i, block := range blocks {
+			if end != 0 && block.SeqID > end {
+				blocks = blocks[:i]
+				full = false
+				break
+			}
+		}
+		if len(blocks) == 0 {
+			break
+		}
+
+		first, last := blocks[0].SeqID, blocks[len(blocks)-1].SeqID
+		entries, err := s.dao.GetEntriesBetween(chainID, first, last)
+		if err != nil {
+			return fmt.Errorf("getting entries [%d - %d]: %w", first, last, err)
+		}
+
+		err = archive.WritePage(blocks, entries)
+		if err != nil {
+			return fmt.Errorf("writing blocks [%d - %d]: %w", first, last, err)
+		}
+
+		if !full {
+			break
+		}
+		from = last + 1
+	}
+
+	err := archive.Close()
+	if err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	return nil
+}
+
+// Import - validate every block and entry of an archive with the trusted keys and store the new ones
+// the keys on the manifest are informative, the signing keys must be configured on this chain
+func (s *blockChainService) Import(ctx context.Context, r io.Reader) (*blockchain.ArchiveManifest, int, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.Import", nil)
+	defer tracer.Finish()
+
+	archive, err := blockchain.ReadArchive(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading archive: %w", err)
+	}
+
+	err = s.LoadKeys(sCtx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("loading keys: %w", err)
+	}
+
+	err = s.chain.ValidateBlocks(archive.Blocks)
+	if err != nil {
+		return nil, 0, fmt.Errorf("validating archive blocks: %w", err)
+	}
+
+	blockEntries := archive.BlockEntries()
+	for i := range archive.Blocks {
+		block := &archive.Blocks[i]
+		if block.MerkleRoot() == "" {
+			continue
+		}
+
+		err = block.VerifyEntries(blockEntries[block.ID])
+		if err != nil {
+			return nil, 0, fmt.Errorf("validating entries of block %d: %w", block.SeqID, err)
+		}
+		delete(blockEntries, block.ID)
+	}
+
+	if len(blockEntries) > 0 {
+		return nil, 0, fmt.Errorf("archive has entries of %d blocks out of it", len(blockEntries))
+	}
+
+	imported, err := s.dao.ImportBlocks(sCtx, archive.Blocks, archive.Entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("importing blocks: %w", err)
+	}
+
+	return &archive.Manifest, imported, nil
+}
+
+// Head - the current head of the chain, the empty chainID is the global chain
+func (s *blockChainService) Head(ctx context.Context, chainID string) (*models.ChainHead, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Head", map[string]interface{}{"chain": chainID})
+	defer tracer.Finish()
+
+	head, err := s.dao.GetHead(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("getting head: %w", err)
+	}
+
+	return head, nil
+}
+
+// chainID - sub-chain of the system, the global chain when sub-chains are disabled
+func (s *blockChainService) chainID(systemID string) string {
+	if s.subChains {
+		return systemID
+	}
+	return ""
+}
+
+// ListBlocks - page of the blocks of the system chain with seqID after afterSeq, from the first block when it is nil
+// keyset paginated by seqID, the appends only add blocks after the head so the pages are stable
+func (s *blockChainService) ListBlocks(ctx context.Context, systemID string, afterSeq *uint, limit int) (*models.BlockPage, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.ListBlocks", map[string]interface{}{"system": systemID, "limit": limit})
+	defer tracer.Finish()
+
+	chainID := s.chainID(systemID)
+
+	limit = pageLimit(limit)
+
+	var fromSeq uint
+	if afterSeq != nil {
+		fromSeq = *afterSeq + 1
+	}
+
+	// one more to know if there is a next page
+	blocks, err := s.dao.GetBlocksPage(chainID, fromSeq, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("getting blocks: %w", err)
+	}
+
+	page := &models.BlockPage{Blocks: blocks}
+	if len(blocks) > limit {
+		page.Blocks = blocks[:limit]
+		page.More = true
+	}
+
+	for i := range page.Blocks {
+		err = page.Blocks[i].Unpack()
+		if err != nil {
+			return nil, fmt.Errorf("unpacking block %d: %w", page.Blocks[i].SeqID, err)
+		}
+	}
+
+	if len(page.Blocks) > 0 {
+		next := page.Blocks[len(page.Blocks)-1].SeqID
+		page.Next = &next
+	}
+
+	return page, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"logger/models"
+	"logger/models/dao"
+	"logger/remotes/blockchain"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore - blocks of the chains kept on memory, ordered by seqID
+type memoryStore struct {
+	dao.BlockChain
+
+	blocks      []blockchain.Block
+	anchors     []blockchain.Block
+	checkpoints []blockchain.Checkpoint
+	// job held by another replica
+	locked bool
+	// validation results in the order they were saved
+	validations []models.ValidationResult
+}
+
+func (m *memoryStore) SaveValidation(ctx context.Context, result *models.ValidationResult) error {
+	m.validations = append(m.validations, *result)
+	return nil
+}
+
+func (m *memoryStore) GetValidation(job string) (*models.ValidationResult, error) {
+	if len(m.validations) == 0 {
+		return nil, dao.ErrNotFound
+	}
+	result := m.validations[len(m.validations)-1]
+	return &result, nil
+}
+
+func (m *memoryStore) RunExclusive(ctx context.Context, job string, fn func() error) (bool, error) {
+	if m.locked {
+		return false, nil
+	}
+	return true, fn()
+}
+
+func (m *memoryStore) GetKeyRotations() ([]blockchain.Block, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) GetChainIDs() ([]string, error) {
+	chainIDs := []string{}
+	seen := map[string]bool{}
+	for _, block := range m.blocks {
+		if block.ChainID != "" && !seen[block.ChainID] {
+			seen[block.ChainID] = true
+			chainIDs = append(chainIDs, block.ChainID)
+		}
+	}
+	return chainIDs, nil
+}
+
+func (m *memoryStore) GetLastCheckpoint(chainID string) (*blockchain.Checkpoint, error) {
+	for i := len(m.checkpoints) - 1; i >= 0; i-- {
+		if m.checkpoints[i].ChainID == chainID {
+			checkpoint := m.checkpoints[i]
+			return &checkpoint, nil
+		}
+	}
+	return nil, dao.ErrNotFound
+}
+
+func (m *memoryStore) SaveCheckpoint(ctx context.Context, checkpoint *blockchain.Checkpoint) error {
+	m.checkpoints = append(m.checkpoints, *checkpoint)
+	return nil
+}
+
+func (m *memoryStore) GetBlock(id uuid.UUID) (*blockchain.Block, error) {
+	for i := range m.blocks {
+		if m.blocks[i].ID == id {
+			block := m.blocks[i]
+			return &block, nil
+		}
+	}
+	return nil, dao.ErrNotFound
+}
+
+func (m *memoryStore) GetBlocksPage(chainID string, fromSeqID uint, limit int) ([]blockchain.Block, error) {
+	blocks := []blockchain.Block{}
+	for _, block := range m.blocks {
+		if block.ChainID == chainID && block.SeqID >= fromSeqID && len(blocks) < limit {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func (m *memoryStore) GetBlocksBetween(chainID string, init, end uint) ([]blockchain.Block, error) {
+	blocks := []blockchain.Block{}
+	for _, block := range m.blocks {
+		if block.ChainID == chainID && block.SeqID >= init && (end == 0 || block.SeqID <= end) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func (m *memoryStore) GetEntriesBetween(chainID string, init, end uint) ([]blockchain.Entry, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) GetAnchors() ([]blockchain.Block, error) {
+	return m.anchors, nil
+}
+
+// _newTestService - service over a chain with the genesis and n blocks on the sauron sub-chain
+func _newTestService(t *testing.T, n int) (*blockChainService, *memoryStore) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	chain := blockchain.NewBlockChain("")
+	chain.SetSigner(blockchain.NewEd25519Signer(priv))
+	chain.AddVerifier(blockchain.NewEd25519Verifier(pub))
+
+	err := chain.GenerateGenesis()
+	assert.Nil(t, err)
+
+	store := &memoryStore{blocks: []blockchain.Block{chain.GenesisBlock}}
+	last := chain.GenesisBlock
+	for i := 0; i < n; i++ {
+		block := blockchain.NewBlock("sauron", map[string]interface{}{"i": i})
+		block.ChainID = "sauron"
+
+		newBlock, err := chain.ChainBlocks(&last, block)
+		assert.Nil(t, err)
+		store.blocks = append(store.blocks, *newBlock)
+		last = *newBlock
+	}
+
+	return &blockChainService{dao: store, chain: chain, batchSize: 2, checkpointBlocks: 3, subChains: true}, store
+}
+
+func TestValidateChainFromStart(t *testing.T) {
+	s, store := _newTestService(t, 5)
+
+	validator, err := s.validateChain(context.Background(), "sauron", nil, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, validator.Validated)
+
+	// the prefix of the sub-chain was deleted
+	store.blocks = append(store.blocks[:1], store.blocks[3:]...)
+	_, err = s.validateChain(context.Background(), "sauron", nil, 2)
+	assert.ErrorContains(t, err, "chain sauron starts on block 3")
+
+	// the global chain lost its genesis
+	genesis := store.blocks[0]
+	block, err := s.chain.ChainBlocks(&genesis, blockchain.NewBlock("gandalf", nil))
+	assert.Nil(t, err)
+	store.blocks = append(store.blocks, *block)
+
+	_, err = s.validateChain(context.Background(), "", nil, 2)
+	assert.Nil(t, err)
+
+	store.blocks = store.blocks[1:]
+	_, err = s.validateChain(context.Background(), "", nil, 2)
+	assert.ErrorContains(t, err, "the genesis is missing")
+}
+
+func TestValidateAndCheckpoint(t *testing.T) {
+	s, store := _newTestService(t, 5)
+	ctx := context.Background()
+
+	last, err := s.LastValidation(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+
+	// the validations requested by the api do not sign checkpoints
+	err = s.Validate(ctx, false)
+	assert.Nil(t, err)
+	assert.Empty(t, store.checkpoints)
+
+	// only the sub-chain advanced enough to sign a checkpoint
+	err = s.ValidateAndCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, store.checkpoints, 1)
+	assert.Equal(t, "sauron", store.checkpoints[0].ChainID)
+	assert.Equal(t, uint(5), store.checkpoints[0].SeqID)
+
+	// the head did not move, no checkpoint is added
+	err = s.ValidateAndCheckpoint(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, store.checkpoints, 1)
+
+	// the validation is stored as running and then with its result
+	assert.Len(t, store.validations, 4)
+	assert.Equal(t, models.ValidationRunning, store.validations[2].Status)
+	last, err = s.LastValidation(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ValidationValid, last.Status)
+	assert.True(t, last.Valid)
+	assert.NotEmpty(t, last.Duration)
+
+	// another replica is validating, its result is kept
+	store.locked = true
+	err = s.ValidateAndCheckpoint(ctx)
+	assert.ErrorIs(t, err, ErrValidationRunning)
+	assert.Len(t, store.validations, 4)
+
+	store.locked = false
+	store.blocks[len(store.blocks)-1].Hash = "tampered"
+	err = s.ValidateAndCheckpoint(ctx)
+	assert.NotNil(t, err)
+	last, err = s.LastValidation(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ValidationInvalid, last.Status)
+	assert.False(t, last.Valid)
+	assert.NotEmpty(t, last.Error)
+}
+
+func TestListBlocks(t *testing.T) {
+	s, _ := _newTestService(t, 5)
+	ctx := context.Background()
+
+	page, err := s.ListBlocks(ctx, "sauron", nil, 2)
+	assert.Nil(t, err)
+	assert.Len(t, page.Blocks, 2)
+	assert.True(t, page.More)
+	assert.Equal(t, uint(2), *page.Next)
+
+	// the page ending on the head has no more blocks
+	page, err = s.ListBlocks(ctx, "sauron", page.Next, 3)
+	assert.Nil(t, err)
+	assert.Len(t, page.Blocks, 3)
+	assert.Equal(t, uint(3), page.Blocks[0].SeqID)
+	assert.False(t, page.More)
+	assert.Equal(t, uint(5), *page.Next)
+
+	// after the head the page is empty and has no next page
+	page, err = s.ListBlocks(ctx, "sauron", page.Next, 3)
+	assert.Nil(t, err)
+	assert.Empty(t, page.Blocks)
+	assert.False(t, page.More)
+	assert.Nil(t, page.Next)
+
+	// without sub-chains the system is on the global chain
+	s.subChains = false
+	page, err = s.ListBlocks(ctx, "sauron", nil, 10)
+	assert.Nil(t, err)
+	assert.Len(t, page.Blocks, 1)
+	assert.True(t, page.Blocks[0].IsGenesis())
+}
+
+func TestExport(t *testing.T) {
+	s, _ := _newTestService(t, 5)
+	ctx := context.Background()
+
+	// the range is read by pages of 2 blocks
+	var buf bytes.Buffer
+	err := s.Export(ctx, "sauron", 2, 4, &buf)
+	assert.Nil(t, err)
+
+	archive, err := blockchain.ReadArchive(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, archive.Blocks, 3)
+	assert.Equal(t, uint(2), archive.Manifest.FirstSeqID)
+	assert.Equal(t, uint(4), archive.Manifest.LastSeqID)
+
+	buf.Reset()
+	err = s.Export(ctx, "sauron", 0, 0, &buf)
+	assert.Nil(t, err)
+
+	archive, err = blockchain.ReadArchive(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, archive.Blocks, 5)
+	assert.Equal(t, uint(5), archive.Manifest.LastSeqID)
+
+	// an empty range has only the manifest
+	buf.Reset()
+	err = s.Export(ctx, "sauron", 6, 0, &buf)
+	assert.Nil(t, err)
+
+	archive, err = blockchain.ReadArchive(&buf)
+	assert.Nil(t, err)
+	assert.Empty(t, archive.Blocks)
+}
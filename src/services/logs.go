@@ -2,40 +2,710 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"logger/config"
 	"logger/models"
 	"logger/models/dao"
 	"logger/remotes/blockchain"
+	"reflect"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
 )
 
 type Logs interface {
 	New(ctx context.Context, l *models.Log) (*models.Log, error)
+	NewBulk(ctx context.Context, logs []*models.Log) ([]models.BulkResult, error)
+	Proof(ctx context.Context, id uuid.UUID) (*blockchain.InclusionProof, error)
+	Get(ctx context.Context, id uuid.UUID, verify bool) (*models.Record, error)
+	GetBlock(ctx context.Context, systemID string, seqID uint, verify bool) (*models.Record, error)
+	Search(ctx context.Context, filter models.LogFilter) (*models.LogPage, error)
 }
 
+const defaultPageLimit = 100
+const maxPageLimit = 1000
+
+const maxIdempotencyKeyLength = 255
+
+// maxProofLinks - links returned on a proof, the head of a bounded proof is the last link
+const maxProofLinks = 1000
+
 type logs struct {
-	dao dao.BlockChain
+	dao       dao.BlockChain
+	chain     *blockchain.BlockChain
+	batch     bool
+	subChains bool
+	sealers   *sealers
 }
 
-func NewLogs() Logs {
+func NewLogs(chain *blockchain.BlockChain) Logs {
+	store := dao.NewBlockChainDao(chain)
+	batch := config.Get().Batch
+
 	return &logs{
-		dao: dao.NewBlockChainDao(),
+		dao:       store,
+		chain:     chain,
+		batch:     batch.Size > 1,
+		subChains: config.Get().BlockChain.SubChains,
+		sealers:   newSealers(store, batch.Size, batch.MaxWait),
 	}
 }
 
+// chainID - sub-chain of the system logs, the global chain when sub-chains are disabled
+func (s *logs) chainID(systemID string) string {
+	if s.subChains {
+		return systemID
+	}
+	return ""
+}
+
 func (s *logs) New(ctx context.Context, l *models.Log) (*models.Log, error) {
 	sCtx, tracer := jaeger.SpanTrace(ctx, "service.New", map[string]interface{}{"system": l.SystemID})
 	defer tracer.Finish()
 
+	err := checkLog(l)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.replay(l)
+	if err != nil || stored != nil {
+		return stored, err
+	}
+
+	if s.batch {
+		return s.newEntry(sCtx, l)
+	}
+
 	block := blockchain.NewBlock(l.SystemID, l.Payload, l.ParseTags()...)
+	block.ChainID = s.chainID(l.SystemID)
+	block.IdempotencyKey = l.IdempotencyKey
 
 	signedBlock, err := s.dao.AppendBlock(sCtx, block)
 	if err != nil {
+		// a concurrent retry can store the key first, failing on the unique index
+		stored, replayErr := s.replay(l)
+		if replayErr != nil || stored != nil {
+			return stored, replayErr
+		}
 		return nil, fmt.Errorf("appending block: %w", err)
 	}
 
+	l.ID = signedBlock.ID
 	l.Block = signedBlock
 
 	return l, nil
 }
+
+// checkLog - the log can be stored
+func checkLog(l *models.Log) error {
+	if blockchain.IsReservedSystemID(l.SystemID) {
+		return fmt.Errorf("system id %s is reserved", l.SystemID)
+	}
+	if len(l.IdempotencyKey) > maxIdempotencyKeyLength {
+		return fmt.Errorf("idempotency key longer than %d", maxIdempotencyKeyLength)
+	}
+	// postgres text columns do not store the null character, the payload has it escaped
+	if strings.ContainsRune(l.SystemID+l.Tags+l.IdempotencyKey, 0) {
+		return fmt.Errorf("system id, tags and idempotency key can not have null characters")
+	}
+	_, err := blockchain.CanonicalJSON(l.Payload)
+	if err != nil {
+		return fmt.Errorf("serializing payload: %w", err)
+	}
+	return nil
+}
+
+// ErrIdempotencyConflict - the idempotency key is stored with another log
+var ErrIdempotencyConflict = errors.New("idempotency key already used by another log")
+
+// replay - the log already stored with the idempotency key of l, nil when l has no key or it is not stored
+func (s *logs) replay(l *models.Log) (*models.Log, error) {
+	if l.IdempotencyKey == "" {
+		return nil, nil
+	}
+
+	records, err := s.dao.GetByIdempotencyKeys(l.SystemID, []string{l.IdempotencyKey})
+	if err != nil {
+		return nil, fmt.Errorf("getting log by idempotency key: %w", err)
+	}
+
+	record, ok := records[l.IdempotencyKey]
+	if !ok {
+		return nil, nil
+	}
+
+	return replayRecord(l, record)
+}
+
+// replayRecord - answer l with the log stored with its idempotency key
+// a stored log with another payload or tags is a conflict, the key can not be reused
+func replayRecord(l *models.Log, record models.Record) (*models.Log, error) {
+	same, err := sameLog(l, record)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, fmt.Errorf("%w: %s", ErrIdempotencyConflict, l.IdempotencyKey)
+	}
+
+	l.ID = record.Block.ID
+	l.Block = record.Block
+	l.Entry = record.Entry
+	if record.Entry != nil {
+		l.ID = record.Entry.ID
+	}
+	l.Replayed = true
+
+	return l, nil
+}
+
+// sameLog - the record stores the payload and the tags of the log
+// the stored record is not unpacked, it can be shared by the requests of a batch
+func sameLog(l *models.Log, record models.Record) (bool, error) {
+	transactionStr, tags := record.Block.TransactionStr, record.Block.Tags
+	if record.Entry != nil {
+		transactionStr, tags = record.Entry.TransactionStr, record.Entry.Tags
+	}
+
+	var stored map[string]interface{}
+	err := json.Unmarshal([]byte(transactionStr), &stored)
+	if err != nil {
+		return false, fmt.Errorf("parsing stored transaction: %w", err)
+	}
+
+	sent, err := logTransaction(l)
+	if err != nil {
+		return false, err
+	}
+
+	return tags == l.Tags && reflect.DeepEqual(stored, sent), nil
+}
+
+// sameLogs - the logs have the same payload and tags
+func sameLogs(a, b *models.Log) (bool, error) {
+	first, err := logTransaction(a)
+	if err != nil {
+		return false, err
+	}
+
+	second, err := logTransaction(b)
+	if err != nil {
+		return false, err
+	}
+
+	return a.Tags == b.Tags && reflect.DeepEqual(first, second), nil
+}
+
+// logTransaction - the transaction stored for the log as read back from json
+func logTransaction(l *models.Log) (map[string]interface{}, error) {
+	transaction := make(map[string]interface{}, len(l.Payload)+1)
+	for k, v := range l.Payload {
+		transaction[k] = v
+	}
+	transaction[blockchain.TRANSACTION_CODE_SYSTEM_ID] = l.SystemID
+
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("serializing payload: %w", err)
+	}
+
+	var read map[string]interface{}
+	err = json.Unmarshal(data, &read)
+	if err != nil {
+		return nil, fmt.Errorf("parsing payload: %w", err)
+	}
+
+	return read, nil
+}
+
+// NewBulk - store the valid logs in a single transaction, the invalid ones are reported on their results
+// on batch mode the entries of each chain are sealed in batch blocks of the batch size, without waiting the sealer
+func (s *logs) NewBulk(ctx context.Context, logs []*models.Log) ([]models.BulkResult, error) {
+	sCtx, tracer := jaeger.SpanTrace(ctx, "service.NewBulk", map[string]interface{}{"logs": len(logs)})
+	defer tracer.Finish()
+
+	results := make([]models.BulkResult, len(logs))
+	valid := []int{}
+	for i, l := range logs {
+		results[i].Index = i
+		err := checkLog(l)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	toStore, repeated, err := s.replayBulk(logs, valid, results)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; len(toStore) > 0; attempt++ {
+		if s.batch {
+			err = s.newBulkEntries(sCtx, logs, toStore, results)
+		} else {
+			err = s.newBulkBlocks(sCtx, logs, toStore, results)
+		}
+		if err == nil {
+			break
+		}
+		if attempt == sealAttempts-1 {
+			return nil, err
+		}
+
+		// a concurrent request can store a key first, failing the transaction on the unique index
+		remaining, replayErr := s.replayStored(logs, toStore, results)
+		if replayErr != nil {
+			return nil, replayErr
+		}
+		if len(remaining) == len(toStore) {
+			return nil, err
+		}
+		toStore = remaining
+	}
+
+	err = copyRepeated(logs, repeated, results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// newBulkBlocks - store each valid log on its own block
+func (s *logs) newBulkBlocks(ctx context.Context, logs []*models.Log, valid []int, results []models.BulkResult) error {
+	blocks := make([]*blockchain.Block, len(valid))
+	for i, position := range valid {
+		l := logs[position]
+		blocks[i] = blockchain.NewBlock(l.SystemID, l.Payload, l.ParseTags()...)
+		blocks[i].ChainID = s.chainID(l.SystemID)
+		blocks[i].IdempotencyKey = l.IdempotencyKey
+	}
+
+	signedBlocks, err := s.dao.AppendBlocks(ctx, blocks, nil)
+	if err != nil {
+		return fmt.Errorf("appending blocks: %w", err)
+	}
+
+	for i, position := range valid {
+		block := signedBlocks[i]
+		results[position].ID = &block.ID
+		results[position].BlockID = &block.ID
+		results[position].SeqID = block.SeqID
+	}
+
+	return nil
+}
+
+// replayBulk - fill the results of the logs already stored with their idempotency keys
+// returns the logs to store and the repeated keys of the request, by position, with the position of the first log
+func (s *logs) replayBulk(logs []*models.Log, valid []int, results []models.BulkResult) ([]int, map[int]int, error) {
+	type systemKey struct{ systemID, key string }
+
+	first := map[systemKey]int{}
+	repeated := map[int]int{}
+	positions := []int{}
+	for _, position := range valid {
+		l := logs[position]
+		if l.IdempotencyKey != "" {
+			key := systemKey{l.SystemID, l.IdempotencyKey}
+			if f, ok := first[key]; ok {
+				repeated[position] = f
+				continue
+			}
+			first[key] = position
+		}
+		positions = append(positions, position)
+	}
+
+	toStore, err := s.replayStored(logs, positions, results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return toStore, repeated, nil
+}
+
+// replayStored - fill the results of the logs on the positions already stored with their idempotency keys
+// a key stored with another log is reported as a conflict, returns the positions still to store
+func (s *logs) replayStored(logs []*models.Log, positions []int, results []models.BulkResult) ([]int, error) {
+	keysBySystem := map[string][]string{}
+	for _, position := range positions {
+		l := logs[position]
+		if l.IdempotencyKey != "" {
+			keysBySystem[l.SystemID] = append(keysBySystem[l.SystemID], l.IdempotencyKey)
+		}
+	}
+
+	stored := map[int]bool{}
+	for systemID, keys := range keysBySystem {
+		records, err := s.dao.GetByIdempotencyKeys(systemID, keys)
+		if err != nil {
+			return nil, fmt.Errorf("getting logs of %s by idempotency key: %w", systemID, err)
+		}
+
+		for _, position := range positions {
+			l := logs[position]
+			record, ok := records[l.IdempotencyKey]
+			if l.SystemID != systemID || l.IdempotencyKey == "" || !ok {
+				continue
+			}
+			stored[position] = true
+
+			same, err := sameLog(l, record)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				results[position] = models.BulkResult{Index: position, Error: fmt.Sprintf("%s: %s", ErrIdempotencyConflict, l.IdempotencyKey)}
+				continue
+			}
+
+			id := record.Block.ID
+			if record.Entry != nil {
+				id = record.Entry.ID
+			}
+			results[position].ID = &id
+			results[position].BlockID = &record.Block.ID
+			results[position].SeqID = record.Block.SeqID
+			results[position].Replayed = true
+		}
+	}
+
+	toStore := []int{}
+	for _, position := range positions {
+		if !stored[position] {
+			toStore = append(toStore, position)
+		}
+	}
+
+	return toStore, nil
+}
+
+// copyRepeated - the repeated keys of a bulk request get the result of the first log with the key
+// a repeated key with another log is reported as a conflict
+func copyRepeated(logs []*models.Log, repeated map[int]int, results []models.BulkResult) error {
+	for position, first := range repeated {
+		same, err := sameLogs(logs[position], logs[first])
+		if err != nil {
+			return err
+		}
+		if !same {
+			results[position] = models.BulkResult{Index: position, Error: fmt.Sprintf("%s: %s", ErrIdempotencyConflict, logs[position].IdempotencyKey)}
+			continue
+		}
+
+		results[position] = results[first]
+		results[position].Index = position
+		results[position].Replayed = results[first].Error == ""
+	}
+	return nil
+}
+
+// newBulkEntries - seal the valid logs as entries of batch blocks of their chains
+func (s *logs) newBulkEntries(ctx context.Context, logs []*models.Log, valid []int, results []models.BulkResult) error {
+	size := config.Get().Batch.Size
+
+	chainIDs := []string{}
+	byChain := map[string][]int{}
+	for _, position := range valid {
+		chainID := s.chainID(logs[position].SystemID)
+		if _, ok := byChain[chainID]; !ok {
+			chainIDs = append(chainIDs, chainID)
+		}
+		byChain[chainID] = append(byChain[chainID], position)
+	}
+
+	blocks := []*blockchain.Block{}
+	entries := []*blockchain.Entry{}
+	for _, chainID := range chainIDs {
+		positions := byChain[chainID]
+		for start := 0; start < len(positions); start += size {
+			end := start + size
+			if end > len(positions) {
+				end = len(positions)
+			}
+
+			batch := make([]*blockchain.Entry, end-start)
+			for i, position := range positions[start:end] {
+				l := logs[position]
+				batch[i] = blockchain.NewEntry(l.SystemID, l.Payload, l.ParseTags()...)
+				batch[i].IdempotencyKey = l.IdempotencyKey
+				err := batch[i].HashEntry()
+				if err != nil {
+					return fmt.Errorf("hashing entry %d: %w", position, err)
+				}
+			}
+
+			block, err := blockchain.NewBatchBlock(batch)
+			if err != nil {
+				return fmt.Errorf("creating batch block: %w", err)
+			}
+			block.ChainID = chainID
+
+			blocks = append(blocks, block)
+			entries = append(entries, batch...)
+		}
+	}
+
+	signedBlocks, err := s.dao.AppendBlocks(ctx, blocks, entries)
+	if err != nil {
+		return fmt.Errorf("appending batch blocks: %w", err)
+	}
+
+	blocksByID := make(map[uuid.UUID]*blockchain.Block, len(signedBlocks))
+	for _, block := range signedBlocks {
+		blocksByID[block.ID] = block
+	}
+
+	// the entries were created on the same order of the positions
+	i := 0
+	for _, chainID := range chainIDs {
+		for _, position := range byChain[chainID] {
+			entry := entries[i]
+			block := blocksByID[entry.BlockID]
+			results[position].ID = &entry.ID
+			results[position].BlockID = &block.ID
+			results[position].SeqID = block.SeqID
+			i++
+		}
+	}
+
+	return nil
+}
+
+// newEntry - store the log as an entry of a batch block
+func (s *logs) newEntry(ctx context.Context, l *models.Log) (*models.Log, error) {
+	entry := blockchain.NewEntry(l.SystemID, l.Payload, l.ParseTags()...)
+	entry.IdempotencyKey = l.IdempotencyKey
+
+	sealedBlock, storedEntry, err := s.sealers.get(s.chainID(l.SystemID)).Add(ctx, entry)
+	if err != nil {
+		return nil, fmt.Errorf("sealing entry: %w", err)
+	}
+
+	// the key was stored before or by another request of the batch
+	if storedEntry != entry {
+		return replayRecord(l, models.Record{Block: sealedBlock, Entry: storedEntry})
+	}
+
+	l.ID = storedEntry.ID
+	l.Block = sealedBlock
+	l.Entry = storedEntry
+
+	return l, nil
+}
+
+// Proof - build the inclusion proof of a log, the id can be from a block or from a batch entry
+func (s *logs) Proof(ctx context.Context, id uuid.UUID) (*blockchain.InclusionProof, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Proof", map[string]interface{}{"id": id})
+	defer tracer.Finish()
+
+	var entries []blockchain.Entry
+	blockID := id
+
+	entry, err := s.dao.GetEntry(id)
+	if err != nil && !errors.Is(err, dao.ErrNotFound) {
+		return nil, fmt.Errorf("getting entry: %w", err)
+	}
+
+	if entry != nil {
+		blockID = entry.BlockID
+		entries, err = s.dao.GetEntries(blockID)
+		if err != nil {
+			return nil, fmt.Errorf("getting block entries: %w", err)
+		}
+	}
+
+	block, err := s.dao.GetBlock(blockID)
+	if err != nil {
+		return nil, fmt.Errorf("getting block %s: %w", blockID, err)
+	}
+
+	links, err := s.dao.GetBlocksPage(block.ChainID, block.SeqID+1, maxProofLinks+1)
+	if err != nil {
+		return nil, fmt.Errorf("getting links to head: %w", err)
+	}
+
+	more := len(links) > maxProofLinks
+	if more {
+		links = links[:maxProofLinks]
+	}
+
+	proof, err := s.chain.NewInclusionProof(*block, entry, entries, links)
+	if err != nil {
+		return nil, fmt.Errorf("generating proof: %w", err)
+	}
+	proof.More = more
+
+	return proof, nil
+}
+
+// Get - read a log by the id of its block or of its batch entry
+// when verify is set the block signature and hash are checked, with the entry inclusion on batch blocks
+func (s *logs) Get(ctx context.Context, id uuid.UUID, verify bool) (*models.Record, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Get", map[string]interface{}{"id": id, "verify": verify})
+	defer tracer.Finish()
+
+	blockID := id
+
+	entry, err := s.dao.GetEntry(id)
+	if err != nil && !errors.Is(err, dao.ErrNotFound) {
+		return nil, fmt.Errorf("getting entry: %w", err)
+	}
+
+	if entry != nil {
+		blockID = entry.BlockID
+	}
+
+	block, err := s.dao.GetBlock(blockID)
+	if err != nil {
+		return nil, fmt.Errorf("getting block %s: %w", blockID, err)
+	}
+
+	return s.record(block, entry, verify)
+}
+
+// GetBlock - read the block on the seqID position of the system chain
+func (s *logs) GetBlock(ctx context.Context, systemID string, seqID uint, verify bool) (*models.Record, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.GetBlock", map[string]interface{}{"system": systemID, "seq": seqID, "verify": verify})
+	defer tracer.Finish()
+
+	block, err := s.dao.GetBlockBySeq(s.chainID(systemID), seqID)
+	if err != nil {
+		return nil, fmt.Errorf("getting block %d: %w", seqID, err)
+	}
+
+	return s.record(block, nil, verify)
+}
+
+// record - unpack the stored block and entry, a failed verification is reported on the record
+func (s *logs) record(block *blockchain.Block, entry *blockchain.Entry, verify bool) (*models.Record, error) {
+	record := &models.Record{
+		Block: block,
+		Entry: entry,
+	}
+
+	if verify {
+		record.Verification = &models.Verification{Valid: true}
+		err := s.verify(block, entry)
+		if err != nil {
+			record.Verification = &models.Verification{Error: err.Error()}
+		}
+	}
+
+	err := unpackRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// unpackRecord - fill the transaction, tags and signature of the log from the stored block and entry
+func unpackRecord(record *models.Record) error {
+	block, entry := record.Block, record.Entry
+
+	err := block.Unpack()
+	if err != nil {
+		return fmt.Errorf("unpacking block %s: %w", block.ID, err)
+	}
+	record.Transaction = block.Transaction
+	record.Signature = block.Signature
+	tags := block.Tags
+
+	if entry != nil {
+		err = entry.Unpack()
+		if err != nil {
+			return fmt.Errorf("unpacking entry %s: %w", entry.ID, err)
+		}
+		record.Transaction = entry.Transaction
+		tags = entry.Tags
+	}
+
+	record.Tags = []string{}
+	if tags != "" {
+		record.Tags = strings.Split(tags, models.TAG_SEPARATOR)
+	}
+
+	return nil
+}
+
+// Search - page of the logs matching the filter, the next page starts after the returned cursor
+func (s *logs) Search(ctx context.Context, filter models.LogFilter) (*models.LogPage, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.Search", map[string]interface{}{"system": filter.SystemID, "limit": filter.Limit})
+	defer tracer.Finish()
+
+	filter.Limit = pageLimit(filter.Limit)
+	filter.ChainID = s.chainID(filter.SystemID)
+
+	// one more to know if there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	records, err := s.dao.SearchLogs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("searching logs: %w", err)
+	}
+
+	page := &models.LogPage{Logs: records}
+	if len(records) > limit {
+		page.Logs = records[:limit]
+
+		last := page.Logs[limit-1]
+		cursor := models.LogCursor{ID: last.Block.ID, CreatedAt: last.Block.CreatedAt}
+		if last.Entry != nil {
+			cursor = models.LogCursor{ID: last.Entry.ID, CreatedAt: last.Entry.CreatedAt}
+		}
+		page.Next = cursor.String()
+	}
+
+	for i := range page.Logs {
+		err = unpackRecord(&page.Logs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// pageLimit - limit of a page, the default when not set and at most maxPageLimit
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
+
+// verify - check the block by itself and the entry against the block merkle root
+func (s *logs) verify(block *blockchain.Block, entry *blockchain.Entry) error {
+	err := s.chain.VerifyBlock(block)
+	if err != nil {
+		return fmt.Errorf("verifying block %s: %w", block.ID, err)
+	}
+
+	if entry == nil {
+		return nil
+	}
+
+	entries, err := s.dao.GetEntries(block.ID)
+	if err != nil {
+		return fmt.Errorf("getting block entries: %w", err)
+	}
+
+	err = block.VerifyEntries(entries)
+	if err != nil {
+		return fmt.Errorf("verifying entries of block %s: %w", block.ID, err)
+	}
+
+	return nil
+}
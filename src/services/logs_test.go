@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"logger/models"
+	"logger/models/dao"
+	"logger/remotes/blockchain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkStore - stores the appended blocks, the other dao methods are not used by the bulk requests
+type bulkStore struct {
+	dao.BlockChain
+
+	blocks []*blockchain.Block
+	// logs stored by idempotency key
+	stored map[string]models.Record
+}
+
+func (b *bulkStore) AppendBlocks(ctx context.Context, blocks []*blockchain.Block, entries []*blockchain.Entry) ([]*blockchain.Block, error) {
+	for _, block := range blocks {
+		block.SeqID = uint(len(b.blocks) + 1)
+		b.blocks = append(b.blocks, block)
+	}
+	return blocks, nil
+}
+
+func (b *bulkStore) GetByIdempotencyKeys(systemID string, keys []string) (map[string]models.Record, error) {
+	records := map[string]models.Record{}
+	for _, key := range keys {
+		if record, ok := b.stored[systemID+"\x00"+key]; ok {
+			records[key] = record
+		}
+	}
+	return records, nil
+}
+
+func TestNewBulkInvalidLogs(t *testing.T) {
+	store := &bulkStore{}
+	s := &logs{dao: store}
+
+	results, err := s.NewBulk(context.Background(), []*models.Log{
+		{SystemID: "sauron", Payload: map[string]interface{}{"n": 1}},
+		{SystemID: "sau\x00ron", Payload: map[string]interface{}{"n": 2}},
+		{SystemID: blockchain.GENESIS_SYSTEM_ID},
+		{SystemID: "sauron", Payload: map[string]interface{}{"text": "null \x00 escaped"}, Tags: "a;b"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+
+	// the invalid logs are reported by position and the others stored
+	assert.Empty(t, results[0].Error)
+	assert.Contains(t, results[1].Error, "null characters")
+	assert.Equal(t, 1, results[1].Index)
+	assert.Contains(t, results[2].Error, "reserved")
+	assert.Empty(t, results[3].Error)
+	assert.Equal(t, uint(2), results[3].SeqID)
+	assert.Len(t, store.blocks, 2)
+}
+
+func TestNewIdempotencyConflict(t *testing.T) {
+	stored := _storedEntry(t, "key", map[string]interface{}{"log": "test"})
+	store := &fakeStore{stored: map[string]models.Record{"system\x00key": stored}}
+	s := &logs{dao: store, batch: true, sealers: newSealers(store, 1, time.Hour)}
+
+	// a retry gets the stored log
+	l, err := s.New(context.Background(), &models.Log{SystemID: "system", Payload: map[string]interface{}{"log": "test"}, IdempotencyKey: "key"})
+	assert.Nil(t, err)
+	assert.True(t, l.Replayed)
+	assert.Equal(t, stored.Entry.ID, l.ID)
+
+	// the key can not be reused with another payload
+	_, err = s.New(context.Background(), &models.Log{SystemID: "system", Payload: map[string]interface{}{"log": "other"}, IdempotencyKey: "key"})
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+	assert.Empty(t, store.sealed())
+}
+
+func TestNewConcurrentDuplicate(t *testing.T) {
+	stored := _storedEntry(t, "key", map[string]interface{}{"log": "test"})
+	store := &fakeStore{concurrent: map[string]models.Record{"system\x00key": stored}}
+	s := &logs{dao: store, batch: true, sealers: newSealers(store, 1, time.Hour)}
+
+	// another replica stores the key while the log is sealed
+	l, err := s.New(context.Background(), &models.Log{SystemID: "system", Payload: map[string]interface{}{"log": "test"}, IdempotencyKey: "key"})
+	assert.Nil(t, err)
+	assert.True(t, l.Replayed)
+	assert.Equal(t, stored.Entry.ID, l.ID)
+
+	store.concurrent = map[string]models.Record{"system\x00late": _storedEntry(t, "late", map[string]interface{}{"log": "test"})}
+	_, err = s.New(context.Background(), &models.Log{SystemID: "system", Payload: map[string]interface{}{"log": "other"}, IdempotencyKey: "late"})
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+	assert.Empty(t, store.sealed())
+}
+
+func TestNewBulkIdempotencyConflict(t *testing.T) {
+	stored := _storedEntry(t, "key", map[string]interface{}{"log": "test"})
+	store := &bulkStore{stored: map[string]models.Record{"system\x00key": stored}}
+	s := &logs{dao: store}
+
+	results, err := s.NewBulk(context.Background(), []*models.Log{
+		{SystemID: "system", Payload: map[string]interface{}{"log": "test"}, IdempotencyKey: "key"},
+		{SystemID: "system", Payload: map[string]interface{}{"log": "other"}, IdempotencyKey: "key"},
+		{SystemID: "system", Payload: map[string]interface{}{"n": 1}, IdempotencyKey: "new"},
+		{SystemID: "system", Payload: map[string]interface{}{"n": 2}, IdempotencyKey: "new"},
+		{SystemID: "system", Payload: map[string]interface{}{"n": 1}, IdempotencyKey: "new"},
+	})
+	assert.Nil(t, err)
+
+	assert.True(t, results[0].Replayed)
+	assert.Equal(t, stored.Entry.ID, *results[0].ID)
+	assert.Contains(t, results[1].Error, ErrIdempotencyConflict.Error())
+	assert.Empty(t, results[2].Error)
+	assert.Contains(t, results[3].Error, ErrIdempotencyConflict.Error())
+	assert.True(t, results[4].Replayed)
+	assert.Equal(t, *results[2].ID, *results[4].ID)
+	assert.Len(t, store.blocks, 1)
+}
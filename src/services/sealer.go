@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"logger/models"
+	"logger/models/dao"
+	"logger/remotes/blockchain"
+	"sync"
+	"time"
+
+	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
+)
+
+type sealResult struct {
+	block *blockchain.Block
+	// entry stored for the request, the first one with its idempotency key on the batch
+	// or the one already stored with the key, nil when the key is stored on its own block
+	entry *blockchain.Entry
+	err   error
+}
+
+type sealRequest struct {
+	entry  *blockchain.Entry
+	result chan sealResult
+}
+
+// sealer - group queued entries in a single block of the chain committed by a merkle root
+// a batch is sealed when it reaches the size or when the first entry waited maxWait
+type sealer struct {
+	dao      dao.BlockChain
+	chainID  string
+	size     int
+	maxWait  time.Duration
+	requests chan sealRequest
+}
+
+// sealers - the sealers of a service by chainID, each chain seals its own batches
+type sealers struct {
+	mu      sync.Mutex
+	dao     dao.BlockChain
+	size    int
+	maxWait time.Duration
+	chains  map[string]*sealer
+}
+
+func newSealers(store dao.BlockChain, size int, maxWait time.Duration) *sealers {
+	return &sealers{
+		dao:     store,
+		size:    size,
+		maxWait: maxWait,
+		chains:  map[string]*sealer{},
+	}
+}
+
+// get - the sealer of the chain, started on the first use
+func (s *sealers) get(chainID string) *sealer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if chainSealer, ok := s.chains[chainID]; ok {
+		return chainSealer
+	}
+
+	chainSealer := newSealer(s.dao, chainID, s.size, s.maxWait)
+	s.chains[chainID] = chainSealer
+
+	return chainSealer
+}
+
+// newSealer - start a sealer of the chain, it runs until the process ends
+func newSealer(store dao.BlockChain, chainID string, size int, maxWait time.Duration) *sealer {
+	s := &sealer{
+		dao:      store,
+		chainID:  chainID,
+		size:     size,
+		maxWait:  maxWait,
+		requests: make(chan sealRequest, size),
+	}
+	go s.run()
+
+	return s
+}
+
+// Add - queue the entry and wait until the block with it is sealed, returns the block and the stored entry
+func (s *sealer) Add(ctx context.Context, entry *blockchain.Entry) (*blockchain.Block, *blockchain.Entry, error) {
+	_, tracer := jaeger.SpanTrace(ctx, "service.sealer.Add", map[string]interface{}{"id": entry.ID})
+	defer tracer.Finish()
+
+	err := entry.HashEntry()
+	if err != nil {
+		return nil, nil, fmt.Errorf("hashing entry: %w", err)
+	}
+
+	result := make(chan sealResult, 1)
+	select {
+	case s.requests <- sealRequest{entry: entry, result: result}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	// once queued the entry will be sealed, wait for it even if the caller gave up
+	r := <-result
+	return r.block, r.entry, r.err
+}
+
+func (s *sealer) run() {
+	var batch []sealRequest
+	timer := time.NewTimer(s.maxWait)
+	timer.Stop()
+
+	for {
+		select {
+		case req := <-s.requests:
+			batch = append(batch, req)
+			if len(batch) == 1 {
+				timer.Reset(s.maxWait)
+			}
+
+			if len(batch) < s.size {
+				continue
+			}
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+
+		s.seal(batch)
+		batch = nil
+	}
+}
+
+// sealAttempts - appends of a batch, a failed append is tried again without the keys stored meanwhile
+const sealAttempts = 3
+
+// seal - store the entries of the batch in a batch block
+// the entries with idempotency keys already stored are answered with the stored log, so they do not fail the others
+func (s *sealer) seal(batch []sealRequest) {
+	ctx, tracer := jaeger.SpanTrace(context.Background(), "service.sealer.seal", map[string]interface{}{"chain": s.chainID, "entries": len(batch)})
+	defer tracer.Finish()
+
+	results := make([]sealResult, len(batch))
+
+	// retries queued together are stored once, all of them get the first entry
+	keys := map[string]int{}
+	repeated := map[int]int{}
+	positions := []int{}
+	for i, req := range batch {
+		if req.entry.IdempotencyKey != "" {
+			key := req.entry.SystemID + "\x00" + req.entry.IdempotencyKey
+			if first, ok := keys[key]; ok {
+				repeated[i] = first
+				continue
+			}
+			keys[key] = i
+		}
+		positions = append(positions, i)
+	}
+
+	var err error
+	for attempt := 0; attempt < sealAttempts && len(positions) > 0; attempt++ {
+		remaining, replayErr := s.replayStored(batch, positions, results)
+		if replayErr != nil {
+			err = replayErr
+			break
+		}
+		// a failed append without new stored keys is not a concurrent retry, do not try again
+		if attempt > 0 && len(remaining) == len(positions) {
+			break
+		}
+		positions = remaining
+		if len(positions) == 0 {
+			break
+		}
+
+		var block *blockchain.Block
+		block, err = s.append(ctx, batch, positions)
+		if err == nil {
+			for _, i := range positions {
+				results[i] = sealResult{block: block, entry: batch[i].entry}
+			}
+			break
+		}
+	}
+
+	if err != nil {
+		tracer.SetTag("error", true)
+		for _, i := range positions {
+			results[i] = sealResult{err: err}
+		}
+	}
+
+	for i, first := range repeated {
+		results[i] = results[first]
+	}
+
+	for i, req := range batch {
+		req.result <- results[i]
+	}
+}
+
+// replayStored - answer the entries with idempotency keys already stored with the stored log
+// returns the positions still to store
+func (s *sealer) replayStored(batch []sealRequest, positions []int, results []sealResult) ([]int, error) {
+	keysBySystem := map[string][]string{}
+	for _, i := range positions {
+		entry := batch[i].entry
+		if entry.IdempotencyKey != "" {
+			keysBySystem[entry.SystemID] = append(keysBySystem[entry.SystemID], entry.IdempotencyKey)
+		}
+	}
+	if len(keysBySystem) == 0 {
+		return positions, nil
+	}
+
+	stored := map[string]models.Record{}
+	for systemID, keys := range keysBySystem {
+		records, err := s.dao.GetByIdempotencyKeys(systemID, keys)
+		if err != nil {
+			return nil, fmt.Errorf("getting entries of %s by idempotency key: %w", systemID, err)
+		}
+		for key, record := range records {
+			stored[systemID+"\x00"+key] = record
+		}
+	}
+
+	remaining := []int{}
+	for _, i := range positions {
+		entry := batch[i].entry
+		record, ok := stored[entry.SystemID+"\x00"+entry.IdempotencyKey]
+		if entry.IdempotencyKey == "" || !ok {
+			remaining = append(remaining, i)
+			continue
+		}
+		results[i] = sealResult{block: record.Block, entry: record.Entry}
+	}
+
+	return remaining, nil
+}
+
+// append - chain a batch block with the entries on the positions
+func (s *sealer) append(ctx context.Context, batch []sealRequest, positions []int) (*blockchain.Block, error) {
+	entries := make([]*blockchain.Entry, len(positions))
+	for i, position := range positions {
+		entries[i] = batch[position].entry
+	}
+
+	block, err := blockchain.NewBatchBlock(entries)
+	if err != nil {
+		return nil, fmt.Errorf("creating batch block: %w", err)
+	}
+	block.ChainID = s.chainID
+
+	return s.dao.AppendBatch(ctx, block, entries)
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"logger/models"
+	"logger/models/dao"
+	"logger/remotes/blockchain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore - records the sealed batches and the idempotency keys, the other dao methods are not used by the sealer
+type fakeStore struct {
+	dao.BlockChain
+
+	mu      sync.Mutex
+	batches [][]*blockchain.Entry
+	// logs stored by system and idempotency key
+	stored map[string]models.Record
+	// logs stored by a concurrent request while the batch is sealed, the append fails on their keys
+	concurrent map[string]models.Record
+	err        error
+}
+
+func (f *fakeStore) AppendBatch(ctx context.Context, b *blockchain.Block, entries []*blockchain.Entry) (*blockchain.Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if f.stored == nil {
+		f.stored = map[string]models.Record{}
+	}
+	for _, entry := range entries {
+		key := entry.SystemID + "\x00" + entry.IdempotencyKey
+		if record, ok := f.concurrent[key]; ok {
+			delete(f.concurrent, key)
+			f.stored[key] = record
+			return nil, errors.New("duplicate key value violates unique constraint")
+		}
+	}
+	for _, entry := range entries {
+		if entry.IdempotencyKey != "" {
+			f.stored[entry.SystemID+"\x00"+entry.IdempotencyKey] = models.Record{Block: b, Entry: entry}
+		}
+	}
+
+	f.batches = append(f.batches, entries)
+	return b, nil
+}
+
+func (f *fakeStore) GetByIdempotencyKeys(systemID string, keys []string) (map[string]models.Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	records := map[string]models.Record{}
+	for _, key := range keys {
+		if record, ok := f.stored[systemID+"\x00"+key]; ok {
+			records[key] = record
+		}
+	}
+	return records, nil
+}
+
+// _storedEntry - entry of the system already sealed on its own batch block with the key
+func _storedEntry(t *testing.T, key string, transaction map[string]interface{}) models.Record {
+	entry := blockchain.NewEntry("system", transaction)
+	entry.IdempotencyKey = key
+	err := entry.HashEntry()
+	assert.Nil(t, err)
+
+	block, err := blockchain.NewBatchBlock([]*blockchain.Entry{entry})
+	assert.Nil(t, err)
+
+	return models.Record{Block: block, Entry: entry}
+}
+
+func (f *fakeStore) sealed() [][]*blockchain.Entry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batches
+}
+
+type added struct {
+	block *blockchain.Block
+	entry *blockchain.Entry
+	err   error
+}
+
+func _addEntries(s *sealer, entries ...*blockchain.Entry) []added {
+	results := make([]added, len(entries))
+	wg := sync.WaitGroup{}
+	wg.Add(len(entries))
+	for i := range entries {
+		go func(i int) {
+			defer wg.Done()
+			r := &results[i]
+			r.block, r.entry, r.err = s.Add(context.Background(), entries[i])
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func _mockEntry(key string) *blockchain.Entry {
+	entry := blockchain.NewEntry("system", map[string]interface{}{"log": "test"})
+	entry.IdempotencyKey = key
+	return entry
+}
+
+func TestSealerSizeFlush(t *testing.T) {
+	store := &fakeStore{}
+	// the wait is never reached, the batch is sealed when it is full
+	s := newSealer(store, "", 3, time.Hour)
+
+	results := _addEntries(s, _mockEntry(""), _mockEntry(""), _mockEntry(""))
+
+	assert.Len(t, store.sealed(), 1)
+	assert.Len(t, store.sealed()[0], 3)
+	for _, r := range results {
+		assert.Nil(t, r.err)
+		assert.Equal(t, results[0].block.ID, r.block.ID)
+		assert.Equal(t, r.block.ID, r.entry.BlockID)
+	}
+}
+
+func TestSealerTimeFlush(t *testing.T) {
+	store := &fakeStore{}
+	s := newSealer(store, "", 10, 20*time.Millisecond)
+
+	start := time.Now()
+	results := _addEntries(s, _mockEntry(""), _mockEntry(""))
+
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Len(t, store.sealed(), 1)
+	assert.Len(t, store.sealed()[0], 2)
+	for _, r := range results {
+		assert.Nil(t, r.err)
+		assert.NotNil(t, r.block)
+	}
+}
+
+func TestSealerErrorFanOut(t *testing.T) {
+	store := &fakeStore{err: errors.New("database down")}
+	s := newSealer(store, "", 3, time.Hour)
+
+	results := _addEntries(s, _mockEntry(""), _mockEntry(""), _mockEntry(""))
+
+	for _, r := range results {
+		assert.ErrorContains(t, r.err, "database down")
+		assert.Nil(t, r.block)
+	}
+}
+
+func TestSealerDuplicateKeys(t *testing.T) {
+	store := &fakeStore{}
+	s := newSealer(store, "", 2, time.Hour)
+
+	first, retry := _mockEntry("key"), _mockEntry("key")
+	results := _addEntries(s, first, retry)
+
+	assert.Len(t, store.sealed(), 1)
+	assert.Len(t, store.sealed()[0], 1)
+	assert.Equal(t, results[0].entry.ID, results[1].entry.ID)
+}
+
+func TestSealerStoredKeys(t *testing.T) {
+	stored := _storedEntry(t, "key", map[string]interface{}{"log": "test"})
+	store := &fakeStore{stored: map[string]models.Record{"system\x00key": stored}}
+	s := newSealer(store, "", 2, time.Hour)
+
+	retry, other := _mockEntry("key"), _mockEntry("other")
+	results := _addEntries(s, retry, other)
+
+	// the retry gets the stored entry and does not fail the other entry
+	assert.Len(t, store.sealed(), 1)
+	assert.Len(t, store.sealed()[0], 1)
+	assert.Nil(t, results[0].err)
+	assert.Same(t, stored.Entry, results[0].entry)
+	assert.Nil(t, results[1].err)
+	assert.Same(t, other, results[1].entry)
+}
+
+func TestSealerConcurrentDuplicate(t *testing.T) {
+	stored := _storedEntry(t, "key", map[string]interface{}{"log": "test"})
+	store := &fakeStore{concurrent: map[string]models.Record{"system\x00key": stored}}
+	s := newSealer(store, "", 3, time.Hour)
+
+	// the key is stored by another replica after the check, failing the first append
+	results := _addEntries(s, _mockEntry("key"), _mockEntry(""), _mockEntry("other"))
+
+	assert.Len(t, store.sealed(), 1)
+	assert.Len(t, store.sealed()[0], 2)
+	assert.Same(t, stored.Entry, results[0].entry)
+	for _, r := range results {
+		assert.Nil(t, r.err)
+	}
+}
+
+func TestSealersByInstance(t *testing.T) {
+	first := newSealers(&fakeStore{}, 2, time.Hour)
+	second := newSealers(&fakeStore{}, 2, time.Hour)
+
+	assert.Same(t, first.get("sauron"), first.get("sauron"))
+	assert.NotSame(t, first.get("sauron"), first.get("gandalf"))
+	// each service seals on its own dao
+	assert.NotSame(t, first.get("sauron"), second.get("sauron"))
+}
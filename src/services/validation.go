@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"logger/config"
+	"logger/remotes/notifier"
+	"time"
+
+	"github.com/joaopandolfi/blackwhale/cron"
+	"github.com/joaopandolfi/blackwhale/utils"
+)
+
+// validationJob - cron job validating the blocks appended after the last checkpoint
+type validationJob struct {
+	service   BlockChain
+	notifiers []notifier.Notifier
+	// last failure alerted, the same failure is not alerted again on each run
+	alerted string
+}
+
+func NewValidationJob(service BlockChain, notifiers ...notifier.Notifier) cron.Job {
+	return &validationJob{
+		service:   service,
+		notifiers: notifiers,
+	}
+}
+
+func (j *validationJob) Trigger() bool {
+	return true
+}
+
+// Eval - validate and alert a new failure, the result is stored by the replica running the validation
+func (j *validationJob) Eval() error {
+	ctx := context.Background()
+	startedAt := time.Now()
+
+	err := j.service.ValidateAndCheckpoint(ctx)
+	if errors.Is(err, ErrValidationRunning) {
+		return nil
+	}
+
+	if err == nil {
+		j.alerted = ""
+		return nil
+	}
+
+	if err.Error() != j.alerted {
+		j.alerted = err.Error()
+		utils.Error("[Validation] chain validation failed", j.alerted)
+		j.notify(ctx, notifier.Alert{
+			Service: config.Get().SystemID,
+			Title:   "chain validation failed",
+			Error:   j.alerted,
+			At:      startedAt,
+		})
+	}
+
+	return fmt.Errorf("validating chain: %w", err)
+}
+
+// notify - deliver the alert on every notifier, a failing notifier does not stop the others
+func (j *validationJob) notify(ctx context.Context, alert notifier.Alert) {
+	for _, n := range j.notifiers {
+		err := n.Notify(ctx, alert)
+		if err != nil {
+			utils.Error("[Validation] notifying alert", n.Name(), err.Error())
+		}
+	}
+}
+
+func (j *validationJob) Stop() error {
+	return nil
+}
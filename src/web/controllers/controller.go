@@ -7,6 +7,15 @@ import (
 
 var SystemPermissions = []string{models.PermissionSystem}
 
+// ReadPermissions - any authenticated caller can read the logs
+var ReadPermissions = []string{models.PermissionRoot, models.PermissionAdmin, models.PermissionSystem, models.PermissionUser}
+
+// WritePermissions - callers that can write logs, the system permission only on its systems
+var WritePermissions = []string{models.PermissionRoot, models.PermissionAdmin, models.PermissionSystem}
+
+// AdminPermissions - callers that can validate, export and import the chain
+var AdminPermissions = []string{models.PermissionRoot, models.PermissionAdmin}
+
 // Controller public contract
 type Controller interface {
 	SetupRouter(s *server.Server)
@@ -3,24 +3,30 @@ package health
 import (
 	"net/http"
 
+	"logger/remotes/blockchain"
+	"logger/services"
+	"logger/web"
 	"logger/web/controllers"
 	"logger/web/server"
 
 	"github.com/joaopandolfi/blackwhale/handlers"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
+	"github.com/joaopandolfi/blackwhale/utils"
 	"github.com/opentracing/opentracing-go"
 )
 
 // --- Health ---
 
 type controller struct {
-	s *server.Server
+	s                 *server.Server
+	blockchainService services.BlockChain
 }
 
 // New Health controller
-func New() controllers.Controller {
+func New(chain *blockchain.BlockChain) controllers.Controller {
 	return &controller{
-		s: nil,
+		s:                 nil,
+		blockchainService: services.NewBlockChain(chain),
 	}
 }
 
@@ -32,3 +38,22 @@ func (c *controller) health(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	handlers.Response(w, true, http.StatusOK)
 }
+
+// Validation route - last background validation, the same on every replica
+// out of /health, so the liveness probe does not depend on the database
+func (c *controller) validation(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "health.validation")
+	defer span.Finish()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	result, err := c.blockchainService.LastValidation(ctx)
+	if err != nil {
+		utils.Error("[Health] getting last validation", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		span.SetTag("error", true)
+		return
+	}
+
+	handlers.RESTResponse(w, result)
+}
@@ -7,4 +7,5 @@ func (c *controller) SetupRouter(s *server.Server) {
 	c.s = s
 	c.s.R.HandleFunc("/", c.health).Methods("POST", "GET", "HEAD")
 	c.s.R.HandleFunc("/health", c.health).Methods("POST", "GET", "HEAD")
+	c.s.R.HandleFunc("/health/validation", c.validation).Methods("GET")
 }
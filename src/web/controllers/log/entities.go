@@ -1,20 +1,149 @@
 package log
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"logger/models"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/joaopandolfi/blackwhale/handlers/conjson"
+	"github.com/joaopandolfi/blackwhale/handlers/conjson/transform"
 )
 
 type payload struct {
 	Data     map[string]interface{}
 	SystemID string
 	Tags     []string
+	// IdempotencyKey used when the request has no Idempotency-Key header
+	IdempotencyKey string
 }
 
 func (p *payload) ToLog() *models.Log {
 	return &models.Log{
-		SystemID: p.SystemID,
-		Payload:  p.Data,
-		Tags:     strings.Join(p.Tags, models.TAG_SEPARATOR),
+		SystemID:       p.SystemID,
+		Payload:        p.Data,
+		Tags:           strings.Join(p.Tags, models.TAG_SEPARATOR),
+		IdempotencyKey: p.IdempotencyKey,
+	}
+}
+
+// errBulkTooLarge - the bulk request has more logs than the max accepted
+var errBulkTooLarge = errors.New("too many logs")
+
+// parseBulk - read the logs of a bulk request, a json array or one json object by line when ndjson
+// the logs are decoded one by one, reading stops on the first log over max
+func parseBulk(body io.Reader, ndjson bool, max int) ([]*models.Log, error) {
+	inner := json.NewDecoder(body)
+	decoder := conjson.NewDecoder(inner, transform.ConventionalKeys(), transform.ValidIdentifierKeys())
+
+	if !ndjson {
+		token, err := inner.Token()
+		if err != nil {
+			return nil, fmt.Errorf("decoding logs: %w", err)
+		}
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("decoding logs: expected a json array")
+		}
+	}
+
+	logs := []*models.Log{}
+	for {
+		if !ndjson && !inner.More() {
+			_, err := inner.Token()
+			if err != nil {
+				return nil, fmt.Errorf("decoding logs: %w", err)
+			}
+			return logs, nil
+		}
+
+		var p payload
+		err := decoder.Decode(&p)
+		if ndjson && errors.Is(err, io.EOF) {
+			return logs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding log %d: %w", len(logs), err)
+		}
+		if len(logs) == max {
+			return nil, fmt.Errorf("%w: max %d", errBulkTooLarge, max)
+		}
+		logs = append(logs, p.ToLog())
 	}
 }
+
+// parseLogFilter - read the search filter from the query, the tag and path params can be repeated
+// the dates are RFC 3339
+func parseLogFilter(query url.Values) (models.LogFilter, error) {
+	filter := models.LogFilter{
+		SystemID: query.Get("system"),
+		Tags:     query["tag"],
+		Paths:    query["path"],
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339Nano, from)
+		if err != nil {
+			return filter, fmt.Errorf("parsing from: %w", err)
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339Nano, to)
+		if err != nil {
+			return filter, fmt.Errorf("parsing to: %w", err)
+		}
+	}
+
+	fromSeq, err := parseUintParam(query, "from_seq")
+	if err != nil {
+		return filter, err
+	}
+	filter.FromSeq = fromSeq
+
+	toSeq, err := parseUintParam(query, "to_seq")
+	if err != nil {
+		return filter, err
+	}
+	filter.ToSeq = toSeq
+
+	if toSeq != 0 && toSeq < fromSeq {
+		return filter, fmt.Errorf("to_seq must be bigger than from_seq")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return filter, fmt.Errorf("parsing limit: %w", err)
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		filter.After, err = models.ParseLogCursor(cursor)
+		if err != nil {
+			return filter, fmt.Errorf("parsing cursor: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseUintParam - optional unsigned param, 0 when not set
+func parseUintParam(query url.Values, name string) (uint, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	return uint(parsed), nil
+}
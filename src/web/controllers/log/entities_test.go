@@ -0,0 +1,80 @@
+package log
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseBulk(t *testing.T) {
+	array := `[{"system_id": "a", "data": {"n": 1}, "tags": ["x", "y"]}, {"system_id": "b", "data": {"n": 2}, "idempotency_key": "k1"}]`
+	logs, err := parseBulk(strings.NewReader(array), false, 10)
+	if err != nil {
+		t.Fatalf("parsing array: %s", err)
+	}
+	if len(logs) != 2 || logs[0].SystemID != "a" || logs[0].Tags != "x;y" || logs[1].Payload["n"] != 2.0 || logs[1].IdempotencyKey != "k1" {
+		t.Errorf("unexpected logs: %+v %+v", logs[0], logs[1])
+	}
+
+	ndjson := "{\"system_id\": \"a\", \"data\": {\"n\": 1}}\n{\"system_id\": \"b\", \"data\": {\"n\": 2}}\n{\"system_id\": \"c\"}\n"
+	logs, err = parseBulk(strings.NewReader(ndjson), true, 10)
+	if err != nil {
+		t.Fatalf("parsing ndjson: %s", err)
+	}
+	if len(logs) != 3 || logs[2].SystemID != "c" {
+		t.Errorf("unexpected logs: %+v", logs)
+	}
+
+	_, err = parseBulk(strings.NewReader(ndjson), true, 2)
+	if !errors.Is(err, errBulkTooLarge) {
+		t.Errorf("expected too large got: %v", err)
+	}
+
+	_, err = parseBulk(strings.NewReader(array), false, 1)
+	if !errors.Is(err, errBulkTooLarge) {
+		t.Errorf("expected too large got: %v", err)
+	}
+
+	// the array is not read after the first log over max
+	_, err = parseBulk(strings.NewReader(`[{"system_id": "a"}, {"system_id": "b"}, not json`), false, 1)
+	if !errors.Is(err, errBulkTooLarge) {
+		t.Errorf("expected too large got: %v", err)
+	}
+
+	_, err = parseBulk(strings.NewReader(`{"system_id": "a"}`), false, 10)
+	if err == nil || errors.Is(err, errBulkTooLarge) {
+		t.Errorf("expected decoding error got: %v", err)
+	}
+
+	_, err = parseBulk(strings.NewReader(`[{"system_id": "a"}`), false, 10)
+	if err == nil {
+		t.Errorf("expected decoding error on unterminated array")
+	}
+
+	_, err = parseBulk(strings.NewReader("{\"system_id\": \"a\"}\nnot json"), true, 10)
+	if err == nil || errors.Is(err, errBulkTooLarge) {
+		t.Errorf("expected decoding error got: %v", err)
+	}
+}
+
+func TestParseUintParam(t *testing.T) {
+	query := url.Values{"after_seq": {"42"}, "limit": {"-1"}, "to_seq": {"abc"}}
+
+	after, err := parseUintParam(query, "after_seq")
+	if err != nil || after != 42 {
+		t.Errorf("unexpected after_seq: %d %v", after, err)
+	}
+
+	missing, err := parseUintParam(query, "from_seq")
+	if err != nil || missing != 0 {
+		t.Errorf("unexpected missing param: %d %v", missing, err)
+	}
+
+	for _, name := range []string{"limit", "to_seq"} {
+		_, err = parseUintParam(query, name)
+		if err == nil {
+			t.Errorf("expected error on invalid %s", name)
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package log
 
 import (
+	"errors"
+	"fmt"
+	"logger/config"
+	"logger/models"
+	"logger/models/dao"
+	"logger/remotes/blockchain"
 	"logger/services"
 	"logger/web"
 	"logger/web/controllers"
+	"logger/web/middleware"
 	"logger/web/server"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/joaopandolfi/blackwhale/handlers"
 	"github.com/joaopandolfi/blackwhale/remotes/jaeger"
 	"github.com/joaopandolfi/blackwhale/utils"
@@ -20,14 +29,20 @@ type controller struct {
 	s                 *server.Server
 	log               services.Logs
 	blockchainService services.BlockChain
+	// maxBulk logs accepted by a bulk request
+	maxBulk int
+	// maxImport bytes of an imported archive
+	maxImport int64
 }
 
-// New Health controller
-func New() controllers.Controller {
+// New Log controller
+func New(chain *blockchain.BlockChain) controllers.Controller {
 	return &controller{
 		s:                 nil,
-		log:               services.NewLogs(),
-		blockchainService: services.NewBlockChain(),
+		log:               services.NewLogs(chain),
+		blockchainService: services.NewBlockChain(chain),
+		maxBulk:           config.Get().Batch.MaxBulk,
+		maxImport:         config.Get().Archive.MaxImportBytes,
 	}
 }
 
@@ -45,7 +60,26 @@ func (c *controller) newLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newBlock, err := c.log.New(ctx, p.ToLog())
+	l := p.ToLog()
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		l.IdempotencyKey = key
+	}
+
+	if !middleware.ClaimsFrom(ctx).CanWrite(l.SystemID) {
+		err = fmt.Errorf("can not write on system %s", l.SystemID)
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusForbidden, web.ErrorCodeForbidden, web.ErrorMessageForbidden, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	newBlock, err := c.log.New(ctx, l)
+	if errors.Is(err, services.ErrIdempotencyConflict) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusConflict, web.ErrorCodeIdempotencyConflict, web.ErrorMessageIdempotencyConflict, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
 	if err != nil {
 		utils.CriticalError("[New Log] saving log", msg, err.Error())
 		handlers.ResponseTypedError(w, web.ErrorCodeSave, web.ErrorMessageSave, err)
@@ -54,17 +88,347 @@ func (c *controller) newLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if newBlock.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+		span.SetTag("replayed", true)
+	}
+
 	handlers.RESTResponse(w, newBlock)
 }
 
+func (c *controller) newBulk(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	ndjson := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-ndjson")
+	logs, err := parseBulk(r.Body, ndjson, c.maxBulk)
+	if errors.Is(err, errBulkTooLarge) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusRequestEntityTooLarge, web.ErrorCodeInvalidBody, err.Error(), err)
+		return
+	}
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, web.ErrorMessageInvalidBody, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	// the logs of systems the caller can not write are reported as invalid
+	claims := middleware.ClaimsFrom(ctx)
+	allowed := []*models.Log{}
+	positions := []int{}
+	denied := map[int]string{}
+	for i, l := range logs {
+		if !claims.CanWrite(l.SystemID) {
+			denied[i] = fmt.Sprintf("can not write on system %s", l.SystemID)
+			continue
+		}
+		allowed = append(allowed, l)
+		positions = append(positions, i)
+	}
+
+	stored, err := c.log.NewBulk(ctx, allowed)
+	if err != nil {
+		utils.CriticalError("[New Bulk] saving logs", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSave, web.ErrorMessageSave, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	results := make([]models.BulkResult, len(logs))
+	for i, result := range stored {
+		result.Index = positions[i]
+		results[positions[i]] = result
+	}
+	for i, msg := range denied {
+		results[i] = models.BulkResult{Index: i, Error: msg}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	span.SetTag("logs", len(results))
+	span.SetTag("failed", failed)
+
+	handlers.RESTResponse(w, map[string]interface{}{
+		"results": results,
+		"failed":  failed,
+	})
+}
+
+func (c *controller) getLog(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	id, err := uuid.Parse(handlers.GetVars(r)["id"])
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, "invalid id", err)
+		return
+	}
+
+	verify, _ := strconv.ParseBool(handlers.GetQueryes(r).Get("verify"))
+
+	record, err := c.log.Get(ctx, id, verify)
+	if errors.Is(err, dao.ErrNotFound) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusNotFound, web.ErrorCodeNotFound, web.ErrorMessageNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Get Log] reading log", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSearch, web.ErrorMessageSearch, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	if record.Verification != nil && !record.Verification.Valid {
+		span.SetTag("error", true)
+		span.SetTag("err_msg", record.Verification.Error)
+	}
+
+	handlers.RESTResponse(w, record)
+}
+
+func (c *controller) getBlock(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	seq, err := strconv.ParseUint(handlers.GetVars(r)["seq"], 10, 64)
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, "invalid seq", err)
+		return
+	}
+
+	query := handlers.GetQueryes(r)
+	verify, _ := strconv.ParseBool(query.Get("verify"))
+
+	record, err := c.log.GetBlock(ctx, query.Get("system"), uint(seq), verify)
+	if errors.Is(err, dao.ErrNotFound) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusNotFound, web.ErrorCodeNotFound, web.ErrorMessageNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Get Block] reading block", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSearch, web.ErrorMessageSearch, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	if record.Verification != nil && !record.Verification.Valid {
+		span.SetTag("error", true)
+		span.SetTag("err_msg", record.Verification.Error)
+	}
+
+	handlers.RESTResponse(w, record)
+}
+
+func (c *controller) searchLogs(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	filter, err := parseLogFilter(handlers.GetQueryes(r))
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, err.Error(), err)
+		return
+	}
+
+	page, err := c.log.Search(ctx, filter)
+	if err != nil {
+		utils.CriticalError("[Search Logs] searching logs", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSearch, web.ErrorMessageSearch, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	handlers.RESTResponse(w, page)
+}
+
+func (c *controller) proof(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	id, err := uuid.Parse(handlers.GetVars(r)["id"])
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, "invalid id", err)
+		return
+	}
+
+	proof, err := c.log.Proof(ctx, id)
+	if errors.Is(err, dao.ErrNotFound) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusNotFound, web.ErrorCodeNotFound, web.ErrorMessageNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Proof] generating proof", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	handlers.RESTResponse(w, proof)
+}
+
+func (c *controller) listBlocks(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	query := handlers.GetQueryes(r)
+
+	var afterSeq *uint
+	if query.Get("after_seq") != "" {
+		after, err := parseUintParam(query, "after_seq")
+		if err != nil {
+			handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, err.Error(), err)
+			return
+		}
+		afterSeq = &after
+	}
+
+	limit, err := parseUintParam(query, "limit")
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, err.Error(), err)
+		return
+	}
+
+	page, err := c.blockchainService.ListBlocks(ctx, query.Get("system"), afterSeq, int(limit))
+	if err != nil {
+		utils.CriticalError("[List Blocks] listing blocks", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSearch, web.ErrorMessageSearch, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	handlers.RESTResponse(w, page)
+}
+
+func (c *controller) head(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	head, err := c.blockchainService.Head(ctx, handlers.GetQueryes(r).Get("system"))
+	if errors.Is(err, dao.ErrNotFound) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusNotFound, web.ErrorCodeNotFound, web.ErrorMessageNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Head] getting chain head", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		span.SetTag("error", true)
+		return
+	}
+
+	handlers.RESTResponse(w, head)
+}
+
+// archiveResponse - response streaming an archive, the download headers are sent on the first write
+// so an error before it is still answered as json
+type archiveResponse struct {
+	http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (a *archiveResponse) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.Header().Set("Content-Type", "application/gzip")
+		a.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", a.filename))
+		a.WriteHeader(http.StatusOK)
+	}
+	return a.ResponseWriter.Write(p)
+}
+
+func (c *controller) export(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	query := handlers.GetQueryes(r)
+	system := query.Get("system")
+	from, err := parseUintParam(query, "from")
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, err.Error(), err)
+		return
+	}
+	to, err := parseUintParam(query, "to")
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, err.Error(), err)
+		return
+	}
+
+	if to != 0 && to < from {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, "to must be bigger than from", nil)
+		return
+	}
+
+	name := "chain"
+	if system != "" {
+		name = fmt.Sprintf("chain-%s", system)
+	}
+	archive := &archiveResponse{ResponseWriter: w, filename: fmt.Sprintf("%s-%d-%d.tar.gz", name, from, to)}
+
+	err = c.blockchainService.Export(ctx, system, from, to, archive)
+	if err != nil {
+		utils.CriticalError("[Export] exporting chain", err.Error())
+		span.SetTag("error", true)
+		if archive.started {
+			// the archive sent is incomplete, the connection is aborted so the client does not take it as complete
+			panic(http.ErrAbortHandler)
+		}
+		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		return
+	}
+}
+
+func (c *controller) importArchive(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	body := http.MaxBytesReader(w, r.Body, c.maxImport)
+	manifest, imported, err := c.blockchainService.Import(ctx, body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusRequestEntityTooLarge, web.ErrorCodeInvalidBody, fmt.Sprintf("archive bigger than %d bytes", tooLarge.Limit), err)
+		span.SetTag("error", true)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Import] importing chain", err.Error())
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusUnprocessableEntity, web.ErrorCodeSave, web.ErrorMessageSave, err)
+		span.SetTag("error", true)
+		span.SetTag("err_msg", err.Error())
+		return
+	}
+
+	handlers.RESTResponse(w, map[string]interface{}{
+		"manifest": manifest,
+		"imported": imported,
+	})
+}
+
 func (c *controller) validate(w http.ResponseWriter, r *http.Request) {
 	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
 	defer span.Finish()
 
-	err := c.blockchainService.Validate(ctx)
+	query := handlers.GetQueryes(r)
+	full, _ := strconv.ParseBool(query.Get("full"))
+
+	var err error
+	if system := query.Get("system"); system != "" {
+		err = c.blockchainService.ValidateChain(ctx, system, full)
+	} else {
+		err = c.blockchainService.Validate(ctx, full)
+	}
 	if err != nil {
 		utils.CriticalError("[Validate] validating chain", err.Error())
-		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		responseValidationError(w, err)
 		span.SetTag("error", true)
 		return
 	}
@@ -72,6 +436,26 @@ func (c *controller) validate(w http.ResponseWriter, r *http.Request) {
 	handlers.Response(w, true, http.StatusOK)
 }
 
+func (c *controller) validationReport(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	report, err := c.blockchainService.Diagnose(ctx, handlers.GetQueryes(r).Get("system"))
+	if err != nil {
+		utils.CriticalError("[Validation Report] diagnosing chain", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		span.SetTag("error", true)
+		return
+	}
+
+	if !report.Valid {
+		span.SetTag("error", true)
+		span.SetTag("issues", len(report.Issues))
+	}
+
+	handlers.RESTResponse(w, report)
+}
+
 func (c *controller) validateSegment(w http.ResponseWriter, r *http.Request) {
 	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
 	defer span.Finish()
@@ -85,13 +469,63 @@ func (c *controller) validateSegment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := c.blockchainService.ValidateSegment(ctx, init, end)
+	system := handlers.GetQueryes(r).Get("system")
+	err := c.blockchainService.ValidateSegment(ctx, system, init, end)
 	if err != nil {
 		utils.CriticalError("[Validate Segment] validating chain", err.Error())
-		handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+		responseValidationError(w, err)
 		span.SetTag("error", true)
 		return
 	}
 
 	handlers.Response(w, true, http.StatusOK)
 }
+
+func (c *controller) checkpoints(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	checkpoints, err := c.blockchainService.Checkpoints(ctx, handlers.GetQueryes(r).Get("system"))
+	if err != nil {
+		utils.CriticalError("[Checkpoints] listing checkpoints", err.Error())
+		handlers.ResponseTypedError(w, web.ErrorCodeSearch, web.ErrorMessageSearch, err)
+		span.SetTag("error", true)
+		return
+	}
+
+	handlers.RESTResponse(w, checkpoints)
+}
+
+func (c *controller) verifyCheckpoint(w http.ResponseWriter, r *http.Request) {
+	ctx, span := jaeger.StartSpanFromRequest(opentracing.GlobalTracer(), r, "log")
+	defer span.Finish()
+
+	id, err := uuid.Parse(handlers.GetVars(r)["id"])
+	if err != nil {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusBadRequest, web.ErrorCodeInvalidBody, "invalid id", err)
+		return
+	}
+
+	checkpoint, err := c.blockchainService.VerifyCheckpoint(ctx, id)
+	if errors.Is(err, dao.ErrNotFound) {
+		handlers.ResponseTypedErrorWithStatus(w, http.StatusNotFound, web.ErrorCodeNotFound, web.ErrorMessageNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.CriticalError("[Verify Checkpoint] verifying checkpoint", err.Error())
+		responseValidationError(w, err)
+		span.SetTag("error", true)
+		return
+	}
+
+	handlers.RESTResponse(w, checkpoint)
+}
+
+// responseValidationError - metadata tampering is reported with its own code
+func responseValidationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, blockchain.ErrMetadataTampered) {
+		handlers.ResponseTypedError(w, web.ErrorCodeMetadataTampered, web.ErrorMessageMetadataTampered, err)
+		return
+	}
+	handlers.ResponseTypedError(w, web.ErrorCodeInternal, web.ErrorMessageInternal, err)
+}
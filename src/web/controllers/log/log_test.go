@@ -0,0 +1,79 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"logger/remotes/blockchain"
+	"logger/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// archiveService - blockchain service exporting and importing archives, the other methods are not used
+type archiveService struct {
+	services.BlockChain
+
+	exported string
+	err      error
+}
+
+func (a *archiveService) Export(ctx context.Context, chainID string, init, end uint, w io.Writer) error {
+	if a.exported != "" {
+		w.Write([]byte(a.exported))
+	}
+	return a.err
+}
+
+func (a *archiveService) Import(ctx context.Context, r io.Reader) (*blockchain.ArchiveManifest, int, error) {
+	_, err := io.ReadAll(r)
+	return nil, 0, err
+}
+
+func TestExport(t *testing.T) {
+	c := &controller{blockchainService: &archiveService{exported: "archive"}}
+
+	w := httptest.NewRecorder()
+	c.export(w, httptest.NewRequest(http.MethodGet, "/export?system=sauron&from=1&to=3", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "archive" {
+		t.Fatalf("expected the archive, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Disposition") != "attachment; filename=chain-sauron-1-3.tar.gz" {
+		t.Fatalf("unexpected disposition %s", w.Header().Get("Content-Disposition"))
+	}
+
+	w = httptest.NewRecorder()
+	c.export(w, httptest.NewRequest(http.MethodGet, "/export?from=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request on an invalid from, got %d", w.Code)
+	}
+
+	// an error before the archive starts is answered as json
+	c.blockchainService = &archiveService{err: errors.New("database down")}
+	w = httptest.NewRecorder()
+	c.export(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("expected an internal error, got %d", w.Code)
+	}
+
+	// after it the response is aborted
+	c.blockchainService = &archiveService{exported: "arch", err: errors.New("database down")}
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Fatalf("expected the response aborted")
+		}
+	}()
+	c.export(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", nil))
+}
+
+func TestImportTooLarge(t *testing.T) {
+	c := &controller{blockchainService: &archiveService{}, maxImport: 4}
+
+	w := httptest.NewRecorder()
+	c.importArchive(w, httptest.NewRequest(http.MethodPost, "/import", strings.NewReader("archive")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request entity too large, got %d", w.Code)
+	}
+}
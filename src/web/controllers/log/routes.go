@@ -1,11 +1,29 @@
 package log
 
-import "logger/web/server"
+import (
+	"logger/web/controllers"
+	"logger/web/middleware"
+	"logger/web/server"
+)
 
 // SetupRouter -
 func (c *controller) SetupRouter(s *server.Server) {
 	c.s = s
-	c.s.R.HandleFunc("/log", c.newLog).Methods("POST", "HEAD")
-	c.s.R.HandleFunc("/validate", c.validate).Methods("GET", "HEAD")
-	c.s.R.HandleFunc("/validate/{init:[0-9]+}/{end:[0-9]+}", c.validateSegment).Methods("GET", "HEAD")
+	auth := middleware.NewAuth(s.Config.JWTSecret)
+
+	c.s.R.HandleFunc("/log", auth.Permission(c.newLog, controllers.WritePermissions...)).Methods("POST", "HEAD")
+	c.s.R.HandleFunc("/log/{id}", auth.Permission(c.getLog, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/logs/batch", auth.Permission(c.newBulk, controllers.WritePermissions...)).Methods("POST")
+	c.s.R.HandleFunc("/logs", auth.Permission(c.searchLogs, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/log/{id}/proof", auth.Permission(c.proof, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/blocks", auth.Permission(c.listBlocks, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/block/{seq:[0-9]+}", auth.Permission(c.getBlock, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/head", auth.Permission(c.head, controllers.ReadPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/export", auth.Permission(c.export, controllers.AdminPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/import", auth.Permission(c.importArchive, controllers.AdminPermissions...)).Methods("POST")
+	c.s.R.HandleFunc("/validate", auth.Permission(c.validate, controllers.AdminPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/validate/report", auth.Permission(c.validationReport, controllers.AdminPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/validate/checkpoints", auth.Permission(c.checkpoints, controllers.AdminPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/validate/checkpoints/{id}", auth.Permission(c.verifyCheckpoint, controllers.AdminPermissions...)).Methods("GET", "HEAD")
+	c.s.R.HandleFunc("/validate/{init:[0-9]+}/{end:[0-9]+}", auth.Permission(c.validateSegment, controllers.AdminPermissions...)).Methods("GET", "HEAD")
 }
@@ -10,6 +10,9 @@ const (
 	ErrorCodeInvalidToken    = 3
 	ErrorMessageInvalidToken = "invalid token"
 
+	ErrorCodeForbidden    = 4
+	ErrorMessageForbidden = "not authorized"
+
 	ErrorCodeInternal    = 10
 	ErrorMessageInternal = "internal error"
 
@@ -24,4 +27,13 @@ const (
 
 	ErrorCodeSearch    = 24
 	ErrorMessageSearch = "error on search"
+
+	ErrorCodeNotFound    = 25
+	ErrorMessageNotFound = "not found"
+
+	ErrorCodeMetadataTampered    = 26
+	ErrorMessageMetadataTampered = "block metadata tampered"
+
+	ErrorCodeIdempotencyConflict    = 27
+	ErrorMessageIdempotencyConflict = "idempotency key already used by another log"
 )
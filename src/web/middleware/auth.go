@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"logger/models"
+	"logger/web"
+	"net/http"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/joaopandolfi/blackwhale/handlers"
+	"github.com/joaopandolfi/blackwhale/utils"
+)
+
+type claimsKey struct{}
+
+// Claims - caller of the request, read from the bearer token
+type Claims struct {
+	ID string
+	// Permissions separated as the models permissions
+	Permissions string
+	// Systems the caller can write, used by the system permission
+	Systems []string
+}
+
+// HasPermission - the caller has any of the permissions, nil claims have none
+func (c *Claims) HasPermission(permissions ...string) bool {
+	if c == nil {
+		return false
+	}
+
+	for _, permission := range permissions {
+		if models.PermissionContain(c.Permissions, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanWrite - root and admin write any system, the system permission only its systems
+func (c *Claims) CanWrite(systemID string) bool {
+	if c.HasPermission(models.PermissionRoot, models.PermissionAdmin) {
+		return true
+	}
+
+	if !c.HasPermission(models.PermissionSystem) {
+		return false
+	}
+
+	for _, system := range c.Systems {
+		if system == systemID {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseToken - check the HS256 signature and the expiration of the token and read its claims
+// the claims are the ones of the blackwhale tokens (id, permission) and the systems the caller writes
+// a system token without systems writes only the system of its id
+func ParseToken(token, secret string) (*Claims, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("jwt secret not configured")
+	}
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing jwt: %w", err)
+	}
+
+	mapClaims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// the parse only checks exp when it is set, a token without it would never expire
+	if !mapClaims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token without expiration")
+	}
+
+	claims := &Claims{}
+	claims.ID, _ = mapClaims["id"].(string)
+	claims.Permissions, _ = mapClaims["permission"].(string)
+
+	if systems, ok := mapClaims["systems"].([]interface{}); ok {
+		for _, system := range systems {
+			if s, ok := system.(string); ok {
+				claims.Systems = append(claims.Systems, s)
+			}
+		}
+	} else if claims.ID != "" {
+		claims.Systems = []string{claims.ID}
+	}
+
+	return claims, nil
+}
+
+// ClaimsFrom - claims of the authenticated request, nil when it was not authenticated
+func ClaimsFrom(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
+
+// Auth - authenticate the requests by the bearer token signed with the jwt secret
+type Auth struct {
+	secret string
+}
+
+func NewAuth(secret string) *Auth {
+	if secret == "" {
+		utils.CriticalError("[Auth] JWT_SECRET not configured, every authenticated route will be denied")
+	}
+	return &Auth{secret: secret}
+}
+
+// Token - authenticate the request by the bearer token, as the blackwhale handlers.TokenHandler does with the token header
+// the claims are kept on the request context, the id and permissions on the blackwhale headers
+func (a *Auth) Token(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := ParseToken(handlers.ExtractToken(r), a.secret)
+		if err != nil {
+			handlers.ResponseTypedErrorWithStatus(w, http.StatusUnauthorized, web.ErrorCodeInvalidToken, web.ErrorMessageInvalidToken, err)
+			return
+		}
+
+		// set, not added, so headers sent by the client are never read as the caller permissions
+		r.Header.Set(handlers.HEADER_USERID, claims.ID)
+		r.Header.Set(handlers.HEADER_PERMISSION, claims.Permissions)
+
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	}
+}
+
+// Permission - allow the authenticated request when the token has any of the permissions, checked by the blackwhale permission middleware
+func (a *Auth) Permission(next http.HandlerFunc, permissions ...string) http.HandlerFunc {
+	return handlers.Chain(next, handlers.PermissionMiddleware(permissions), a.Token)
+}
@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"logger/models"
+	"logger/web/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/joaopandolfi/blackwhale/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "test secret"
+
+func _token(t *testing.T, claims jwt.MapClaims) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.Nil(t, err)
+	return token
+}
+
+func TestParseToken(t *testing.T) {
+	claims, err := middleware.ParseToken(_token(t, jwt.MapClaims{
+		"id":         "billing",
+		"permission": models.GeneratePermissions(models.PermissionSystem),
+	}), secret)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"billing"}, claims.Systems)
+	assert.True(t, claims.CanWrite("billing"))
+	assert.False(t, claims.CanWrite("orders"))
+
+	claims, err = middleware.ParseToken(_token(t, jwt.MapClaims{
+		"id":         "cdc",
+		"permission": models.PermissionSystem,
+		"systems":    []string{"orders", "payments"},
+	}), secret)
+	assert.Nil(t, err)
+	assert.True(t, claims.CanWrite("payments"))
+	assert.False(t, claims.CanWrite("cdc"))
+
+	claims, err = middleware.ParseToken(_token(t, jwt.MapClaims{"permission": models.PermissionUser}), secret)
+	assert.Nil(t, err)
+	assert.False(t, claims.CanWrite("orders"))
+
+	claims, err = middleware.ParseToken(_token(t, jwt.MapClaims{"permission": models.PermissionAdmin}), secret)
+	assert.Nil(t, err)
+	assert.True(t, claims.CanWrite("orders"))
+
+	_, err = middleware.ParseToken(_token(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), secret)
+	assert.NotNil(t, err)
+
+	// a token without expiration is not accepted
+	forever, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"permission": models.PermissionRoot}).SignedString([]byte(secret))
+	assert.Nil(t, err)
+	_, err = middleware.ParseToken(forever, secret)
+	assert.ErrorContains(t, err, "without expiration")
+
+	_, err = middleware.ParseToken(_token(t, jwt.MapClaims{}), "other secret")
+	assert.NotNil(t, err)
+
+	_, err = middleware.ParseToken(_token(t, jwt.MapClaims{}), "")
+	assert.ErrorContains(t, err, "not configured")
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"permission": models.PermissionRoot}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.Nil(t, err)
+	_, err = middleware.ParseToken(unsigned, secret)
+	assert.NotNil(t, err)
+}
+
+func TestAuthPermission(t *testing.T) {
+	auth := middleware.NewAuth(secret)
+
+	var claims *middleware.Claims
+	handler := auth.Permission(func(w http.ResponseWriter, r *http.Request) {
+		claims = middleware.ClaimsFrom(r.Context())
+	}, models.PermissionRoot, models.PermissionAdmin)
+
+	request := func(token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		// the permissions sent by the client are ignored
+		r.Header.Set(handlers.HEADER_PERMISSION, models.PermissionRoot)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(""))
+	assert.Nil(t, claims)
+
+	assert.Equal(t, http.StatusForbidden, request(_token(t, jwt.MapClaims{"permission": models.PermissionSystem})))
+	assert.Nil(t, claims)
+
+	assert.Equal(t, http.StatusOK, request(_token(t, jwt.MapClaims{
+		"id":         "ops",
+		"permission": models.GeneratePermissions(models.PermissionUser, models.PermissionAdmin),
+	})))
+	assert.Equal(t, "ops", claims.ID)
+}
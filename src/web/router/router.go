@@ -2,6 +2,7 @@ package router
 
 import (
 	"logger/config"
+	"logger/remotes/blockchain"
 	"logger/web/controllers/health"
 	"logger/web/controllers/log"
 	"logger/web/middleware"
@@ -12,12 +13,13 @@ import (
 
 // Router public struct
 type Router struct {
-	s *server.Server
+	s     *server.Server
+	chain *blockchain.BlockChain
 }
 
-// New Router
-func New(s *server.Server) Router {
-	return Router{s: s}
+// New Router, the controllers share the chain
+func New(s *server.Server, chain *blockchain.BlockChain) Router {
+	return Router{s: s, chain: chain}
 }
 
 // Setup router
@@ -26,8 +28,8 @@ func (r *Router) Setup() {
 
 	r.s.R.Methods("OPTIONS").HandlerFunc(middleware.Options)
 
-	health.New().SetupRouter(r.s)
-	log.New().SetupRouter(r.s)
+	health.New(r.chain).SetupRouter(r.s)
+	log.New(r.chain).SetupRouter(r.s)
 }
 
 // CreateSubRouter with path